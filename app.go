@@ -2,37 +2,173 @@ package rapidmin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ankulikov/rapidmin/auth"
 	"github.com/ankulikov/rapidmin/config"
 	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/providers/cache"
 	sqlprovider "github.com/ankulikov/rapidmin/providers/sql"
 	"github.com/ankulikov/rapidmin/server"
 )
 
-func NewServer(cfg config.AppConfig) (*server.Server, error) {
-	registry, err := buildProviders(cfg)
+func NewServer(cfg config.AppConfig, opts ...server.Option) (*server.Server, error) {
+	return NewServerWithLogger(cfg, slog.Default(), opts...)
+}
+
+// NewServerWithLogger is like NewServer but logs requests, errors and SQL
+// queries to logger. It takes precedence over a server.WithLogger option.
+func NewServerWithLogger(cfg config.AppConfig, logger *slog.Logger, opts ...server.Option) (*server.Server, error) {
+	registry, err := buildProviders(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	authOpts, err := buildAuthenticators(cfg)
+	if err != nil {
+		closeProviders(registry)
+		return nil, err
+	}
+
+	auditOpts, err := buildAuditSinks(cfg)
 	if err != nil {
+		closeProviders(registry)
 		return nil, err
 	}
 
-	return server.New(cfg, registry)
+	serverOpts := append(authOpts, auditOpts...)
+	serverOpts = append(serverOpts, opts...)
+	return server.New(cfg, registry, append(serverOpts, server.WithLogger(logger))...)
+}
+
+func buildAuthenticators(cfg config.AppConfig) ([]server.Option, error) {
+	if cfg.Auth == nil {
+		return nil, nil
+	}
+
+	var opts []server.Option
+	var stores []auth.TokenStore
+	if len(cfg.Auth.Tokens) > 0 {
+		tokens, err := auth.NewStaticTokens(cfg.Auth.Tokens)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		stores = append(stores, tokens)
+	}
+	if cfg.Auth.TokensFile != "" {
+		tokens, err := auth.NewFileTokens(cfg.Auth.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		stores = append(stores, tokens)
+	}
+	if len(stores) > 0 {
+		tokens, err := auth.NewTokens(stores...)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		opts = append(opts, server.WithAuthenticator(tokens))
+	}
+
+	if cfg.Auth.OIDC != nil {
+		sessions, err := auth.NewSessions(cfg.Auth.SessionSecret, cfg.Auth.SessionTTL, cfg.PathPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		oidc, err := auth.NewOIDC(context.Background(), *cfg.Auth.OIDC, sessions, auth.WithPathPrefix(cfg.PathPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		opts = append(opts, server.WithAuthenticator(oidc))
+	}
+	return opts, nil
+}
+
+func buildAuditSinks(cfg config.AppConfig) ([]server.Option, error) {
+	if cfg.Audit == nil {
+		return nil, nil
+	}
+
+	var sinks []server.AuditSink
+	if cfg.Audit.File != "" {
+		sink, err := server.NewFileAuditSink(cfg.Audit.File, cfg.Audit.QueueSize)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if cfg.Audit.Provider != "" {
+		providerConfig, ok := cfg.Providers[cfg.Audit.Provider]
+		if !ok || providerConfig.SQL == nil {
+			closeAuditSinks(sinks)
+			return nil, fmt.Errorf("audit provider %s is not a sql provider", cfg.Audit.Provider)
+		}
+		db, err := sqlx.Open(providerConfig.SQL.Driver, providerConfig.SQL.DSN)
+		if err != nil {
+			closeAuditSinks(sinks)
+			return nil, fmt.Errorf("open audit database: %w", err)
+		}
+		sinks = append(sinks, sqlAuditSink{
+			AsyncAuditSink: server.NewSQLAuditSink(db, cfg.Audit.Table, cfg.Audit.QueueSize),
+			db:             db,
+		})
+	}
+
+	opts := make([]server.Option, 0, len(sinks))
+	for _, sink := range sinks {
+		opts = append(opts, server.WithAuditSink(sink))
+	}
+	return opts, nil
+}
+
+func closeAuditSinks(sinks []server.AuditSink) {
+	for _, sink := range sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// sqlAuditSink owns the connection pool of the SQL audit sink.
+type sqlAuditSink struct {
+	*server.AsyncAuditSink
+	db *sqlx.DB
 }
 
-func buildProviders(cfg config.AppConfig) (providers.Registry, error) {
+func (s sqlAuditSink) Close() error {
+	return errors.Join(s.AsyncAuditSink.Close(), s.db.Close())
+}
+
+func buildProviders(cfg config.AppConfig, logger *slog.Logger) (providers.Registry, error) {
 	registry := providers.Registry{}
+	cacheBackend := cache.NewLRU(cache.DefaultCapacity)
 
 	for name, providerConfig := range cfg.Providers {
 		if providerConfig.SQL == nil {
+			closeProviders(registry)
 			return nil, fmt.Errorf("provider %s missing sql config", name)
 		}
-		sqlProvider := sqlprovider.New()
+		sqlProvider := sqlprovider.New(sqlprovider.WithLogger(logger))
 		if err := sqlProvider.Init(context.Background(), name, providerConfig); err != nil {
+			closeProviders(registry)
 			return nil, fmt.Errorf("failed to initialize sql provider %s: %w", name, err)
 		}
 
-		registry[name] = sqlProvider
+		registry[name] = cache.New(sqlProvider, cache.WithBackend(cacheBackend))
 	}
 
 	return registry, nil
 }
+
+func closeProviders(registry providers.Registry) {
+	for _, provider := range registry {
+		if closer, ok := provider.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/server"
+)
+
+const (
+	loginCookie   = "rapidmin_oidc"
+	loginTTL      = 10 * time.Minute
+	clockLeeway   = time.Minute
+	httpTimeout   = 10 * time.Second
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+var defaultScopes = []string{"openid", "profile", "email"}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// loginState is kept in a signed cookie between login and callback.
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+	Expires  int64  `json:"exp"`
+}
+
+// OIDC logs users in with the OpenID Connect authorization code flow and
+// PKCE, and keeps them logged in with a session cookie. It serves
+// /auth/login, /auth/callback and /auth/logout.
+type OIDC struct {
+	cfg        config.OIDCConfig
+	sessions   *Sessions
+	client     *http.Client
+	pathPrefix string
+	provider   discovery
+	keys       *keySet
+	now        func() time.Time
+}
+
+type Option func(*OIDC)
+
+// WithPathPrefix sets the path prefix the server is mounted under.
+func WithPathPrefix(prefix string) Option {
+	return func(o *OIDC) {
+		o.pathPrefix = strings.TrimSuffix(cookiePath(prefix), "/")
+	}
+}
+
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *OIDC) {
+		o.client = client
+	}
+}
+
+// NewOIDC discovers the provider configuration of cfg.Issuer.
+func NewOIDC(ctx context.Context, cfg config.OIDCConfig, sessions *Sessions, opts ...Option) (*OIDC, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("oidc issuer and client_id are required")
+	}
+	if sessions == nil {
+		return nil, errors.New("oidc requires sessions")
+	}
+
+	o := &OIDC{
+		cfg:      cfg,
+		sessions: sessions,
+		client:   &http.Client{Timeout: httpTimeout},
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	if err := getJSON(ctx, o.client, issuer+discoveryPath, &o.provider); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(o.provider.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match %q", o.provider.Issuer, cfg.Issuer)
+	}
+	if o.provider.AuthorizationEndpoint == "" || o.provider.TokenEndpoint == "" || o.provider.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: missing endpoints")
+	}
+	o.keys = &keySet{uri: o.provider.JWKSURI, client: o.client}
+
+	return o, nil
+}
+
+// Authenticate returns the identity of the session cookie.
+func (o *OIDC) Authenticate(r *http.Request) (server.Identity, bool) {
+	return o.sessions.Authenticate(r)
+}
+
+func (o *OIDC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/auth") {
+	case "/login":
+		o.handleLogin(w, r)
+	case "/callback":
+		o.handleCallback(w, r)
+	case "/logout":
+		o.handleLogout(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+	}
+}
+
+func (o *OIDC) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	state := loginState{
+		State:    randomToken(),
+		Nonce:    randomToken(),
+		Verifier: randomToken(),
+		ReturnTo: o.returnTo(r.URL.Query().Get("return_to")),
+		Expires:  o.now().Add(loginTTL).Unix(),
+	}
+	value, err := o.sessions.encode(loginCookie, state)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal error")
+		return
+	}
+	o.sessions.setCookie(w, r, loginCookie, value, o.now().Add(loginTTL))
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.cfg.ClientID},
+		"redirect_uri":          {o.redirectURL(r)},
+		"scope":                 {strings.Join(o.scopes(), " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	target := o.provider.AuthorizationEndpoint
+	if strings.Contains(target, "?") {
+		target += "&" + query.Encode()
+	} else {
+		target += "?" + query.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (o *OIDC) handleCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+
+	var state loginState
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || !o.sessions.decode(loginCookie, cookie.Value, &state) || o.now().Unix() >= state.Expires {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "login expired, please try again")
+		return
+	}
+	o.sessions.setCookie(w, r, loginCookie, "", time.Unix(0, 0))
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid login state")
+		return
+	}
+	if query.Get("error") != "" {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "login failed: "+query.Get("error"))
+		return
+	}
+
+	rawIDToken, err := o.exchange(r.Context(), query.Get("code"), state.Verifier, o.redirectURL(r))
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "unauthorized", "login failed: "+err.Error())
+		return
+	}
+	claims, err := o.verifyIDToken(r.Context(), rawIDToken, state.Nonce)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "login failed: "+err.Error())
+		return
+	}
+
+	if err := o.sessions.Start(w, r, o.identity(claims)); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "internal error")
+		return
+	}
+	http.Redirect(w, r, state.ReturnTo, http.StatusFound)
+}
+
+func (o *OIDC) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		return
+	}
+	o.sessions.End(w, r)
+	http.Redirect(w, r, o.pathPrefix+"/", http.StatusSeeOther)
+}
+
+// exchange redeems the authorization code for an ID token.
+func (o *OIDC) exchange(ctx context.Context, code, verifier, redirectURL string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {verifier},
+	}
+	if o.cfg.ClientSecret == "" {
+		form.Set("client_id", o.cfg.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if o.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s", body.Error)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response without id_token")
+	}
+	return body.IDToken, nil
+}
+
+func (o *OIDC) verifyIDToken(ctx context.Context, raw, nonce string) (map[string]any, error) {
+	claims, err := verifyJWT(ctx, o.keys, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); iss != o.provider.Issuer {
+		return nil, errors.New("token issuer mismatch")
+	}
+	if !audienceContains(claims["aud"], o.cfg.ClientID) {
+		return nil, errors.New("token audience mismatch")
+	}
+	now := o.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if iat, ok := claims["iat"].(float64); ok && time.Unix(int64(iat), 0).After(now.Add(clockLeeway)) {
+		return nil, errors.New("token issued in the future")
+	}
+	if got, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return nil, errors.New("token nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("token without subject")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []any:
+		for _, value := range aud {
+			if value == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// identity maps ID token claims to a Rapidmin identity.
+func (o *OIDC) identity(claims map[string]any) server.Identity {
+	id := server.Identity{Subject: claims["sub"].(string)}
+
+	roles := map[string]bool{}
+	for _, value := range claimStrings(claims[o.cfg.RolesClaim]) {
+		if len(o.cfg.RoleMapping) == 0 {
+			roles[value] = true
+		} else if role, ok := o.cfg.RoleMapping[value]; ok {
+			roles[role] = true
+		}
+	}
+	for role := range roles {
+		id.Roles = append(id.Roles, role)
+	}
+	sort.Strings(id.Roles)
+
+	for name, claim := range o.cfg.Attributes {
+		values := claimStrings(claims[claim])
+		if len(values) > 0 {
+			if id.Attributes == nil {
+				id.Attributes = map[string]string{}
+			}
+			id.Attributes[name] = strings.Join(values, ",")
+		}
+	}
+	return id
+}
+
+func claimStrings(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case float64, bool:
+		return []string{fmt.Sprint(value)}
+	case []any:
+		var values []string
+		for _, item := range value {
+			values = append(values, claimStrings(item)...)
+		}
+		return values
+	}
+	return nil
+}
+
+func (o *OIDC) scopes() []string {
+	if len(o.cfg.Scopes) == 0 {
+		return defaultScopes
+	}
+	return o.cfg.Scopes
+}
+
+func (o *OIDC) redirectURL(r *http.Request) string {
+	if o.cfg.RedirectURL != "" {
+		return o.cfg.RedirectURL
+	}
+	scheme := "http"
+	if isHTTPS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + o.pathPrefix + "/auth/callback"
+}
+
+// returnTo only accepts local paths so the login cannot redirect to other
+// sites.
+func (o *OIDC) returnTo(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return o.pathPrefix + "/"
+	}
+	return target
+}
+
+func randomToken() string {
+	buf := make([]byte, 32)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]string{"code": code, "message": message}})
+}
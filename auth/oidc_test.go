@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/server"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// mockIssuer is a minimal OpenID provider that approves every login.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any
+
+	mu     sync.Mutex
+	logins map[string]url.Values
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m := &mockIssuer{t: t, key: key, logins: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := randomToken()
+		m.mu.Lock()
+		m.logins[code] = query
+		m.mu.Unlock()
+		target := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, target, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "rapidmin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		m.mu.Lock()
+		login, ok := m.logins[r.PostFormValue("code")]
+		delete(m.logins, r.PostFormValue("code"))
+		m.mu.Unlock()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != login.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := m.defaultClaims(login.Get("nonce"))
+		for key, value := range m.claims {
+			claims[key] = value
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(claims)})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) defaultClaims(nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":    m.server.URL,
+		"aud":    "rapidmin",
+		"sub":    "user-1",
+		"iat":    now.Unix(),
+		"exp":    now.Add(time.Hour).Unix(),
+		"nonce":  nonce,
+		"groups": []string{"admins", "staff", "unmapped"},
+		"email":  "ada@example.com",
+	}
+}
+
+func (m *mockIssuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	if err != nil {
+		m.t.Fatalf("sign token: %v", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (m *mockIssuer) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:       m.server.URL,
+		ClientID:     "rapidmin",
+		ClientSecret: "secret",
+		RolesClaim:   "groups",
+		RoleMapping:  map[string]string{"admins": "admin", "staff": "viewer"},
+		Attributes:   map[string]string{"email": "email"},
+	}
+}
+
+func newTestOIDC(t *testing.T, issuer *mockIssuer, prefix string) *OIDC {
+	sessions, err := NewSessions(testSecret, time.Hour, prefix)
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	oidc, err := NewOIDC(context.Background(), issuer.config(), sessions, WithPathPrefix(prefix))
+	if err != nil {
+		t.Fatalf("oidc: %v", err)
+	}
+	return oidc
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	issuer := newMockIssuer(t)
+	oidc := newTestOIDC(t, issuer, "/admin")
+
+	srv, err := server.New(config.AppConfig{PathPrefix: "/admin"}, providers.Registry{}, server.WithAuthenticator(oidc))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	app := httptest.NewServer(srv)
+	defer app.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(app.URL + "/admin/api/me")
+	if err != nil {
+		t.Fatalf("get me: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 before login, got %d", resp.StatusCode)
+	}
+
+	resp, err = client.Get(app.URL + "/admin/reports?tab=1")
+	if err != nil {
+		t.Fatalf("login flow: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Request.URL.RequestURI(); got != "/admin/reports?tab=1" {
+		t.Fatalf("expected to return to /admin/reports?tab=1, landed on %s", got)
+	}
+
+	resp, err = client.Get(app.URL + "/admin/api/me")
+	if err != nil {
+		t.Fatalf("get me: %v", err)
+	}
+	var id server.Identity
+	if err := json.NewDecoder(resp.Body).Decode(&id); err != nil {
+		t.Fatalf("decode me: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 after login, got %d", resp.StatusCode)
+	}
+	if id.Subject != "user-1" || strings.Join(id.Roles, ",") != "admin,viewer" || id.Attributes["email"] != "ada@example.com" {
+		t.Fatalf("unexpected identity: %+v", id)
+	}
+
+	// The mock issuer approves logins silently, so following the redirect
+	// after logout would log the user in again.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err = client.Post(app.URL+"/admin/auth/logout", "", nil)
+	if err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/admin/" {
+		t.Fatalf("expected logout to redirect to /admin/, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	resp, err = client.Get(app.URL + "/admin/api/me")
+	if err != nil {
+		t.Fatalf("get me: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after logout, got %d", resp.StatusCode)
+	}
+}
+
+func TestOIDCRedirects(t *testing.T) {
+	issuer := newMockIssuer(t)
+	oidc := newTestOIDC(t, issuer, "")
+
+	srv, err := server.New(config.AppConfig{}, providers.Registry{}, server.WithAuthenticator(oidc))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/auth/login?return_to=%2Fdashboard" {
+		t.Fatalf("expected redirect to login, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || rec.Code != http.StatusFound {
+		t.Fatalf("expected redirect to issuer, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	query := location.Query()
+	for key, expected := range map[string]string{
+		"client_id":             "rapidmin",
+		"response_type":         "code",
+		"scope":                 "openid profile email",
+		"redirect_uri":          "http://example.com/auth/callback",
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != expected {
+			t.Fatalf("authorize %s = %q, expected %q", key, got, expected)
+		}
+	}
+
+	for target, expected := range map[string]string{
+		"/reports":           "/reports",
+		"":                   "/",
+		"https://evil.test/": "/",
+		"//evil.test/":       "/",
+		"/\\evil.test/":      "/",
+	} {
+		if got := oidc.returnTo(target); got != expected {
+			t.Fatalf("returnTo(%q) = %q, expected %q", target, got, expected)
+		}
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidState(t *testing.T) {
+	issuer := newMockIssuer(t)
+	oidc := newTestOIDC(t, issuer, "")
+
+	rec := httptest.NewRecorder()
+	oidc.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/login", nil))
+	loginCookie := rec.Result().Cookies()[0]
+
+	for name, req := range map[string]*http.Request{
+		"missing cookie": httptest.NewRequest(http.MethodGet, "/auth/callback?code=x&state=y", nil),
+		"wrong state":    httptest.NewRequest(http.MethodGet, "/auth/callback?code=x&state=y", nil),
+	} {
+		if name == "wrong state" {
+			req.AddCookie(loginCookie)
+		}
+		rec := httptest.NewRecorder()
+		oidc.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	oidc := newTestOIDC(t, issuer, "")
+	other := newMockIssuer(t)
+
+	tests := []struct {
+		name    string
+		mutate  func(map[string]any)
+		signer  *mockIssuer
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "audience list", mutate: func(c map[string]any) { c["aud"] = []string{"other", "rapidmin"} }},
+		{name: "wrong audience", mutate: func(c map[string]any) { c["aud"] = "other" }, wantErr: "audience"},
+		{name: "wrong issuer", mutate: func(c map[string]any) { c["iss"] = "https://evil.test" }, wantErr: "issuer"},
+		{name: "expired", mutate: func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: "expired"},
+		{name: "nonce", mutate: func(c map[string]any) { c["nonce"] = "other" }, wantErr: "nonce"},
+		{name: "foreign key", signer: other, wantErr: "signature"},
+	}
+
+	for _, tt := range tests {
+		claims := issuer.defaultClaims("nonce")
+		if tt.mutate != nil {
+			tt.mutate(claims)
+		}
+		signer := issuer
+		if tt.signer != nil {
+			signer = tt.signer
+		}
+		_, err := oidc.verifyIDToken(context.Background(), signer.sign(claims), "nonce")
+		if tt.wantErr == "" && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Fatalf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestOIDCRoleMapping(t *testing.T) {
+	issuer := newMockIssuer(t)
+	oidc := newTestOIDC(t, issuer, "")
+
+	id := oidc.identity(map[string]any{"sub": "u", "groups": "admins"})
+	if strings.Join(id.Roles, ",") != "admin" {
+		t.Fatalf("expected single string claim to map, got %v", id.Roles)
+	}
+
+	oidc.cfg.RoleMapping = nil
+	id = oidc.identity(map[string]any{"sub": "u", "groups": []any{"b", "a", "b"}})
+	if strings.Join(id.Roles, ",") != "a,b" {
+		t.Fatalf("expected unmapped roles to pass through, got %v", id.Roles)
+	}
+}
@@ -0,0 +1,135 @@
+// Package auth provides authenticators for the server: OpenID Connect login
+// with signed session cookies, and bearer API tokens.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ankulikov/rapidmin/server"
+)
+
+const (
+	SessionCookie     = "rapidmin_session"
+	DefaultSessionTTL = 8 * time.Hour
+
+	minSecretLength = 32
+)
+
+// Sessions stores identities in signed cookies. The cookie is not encrypted,
+// so identities must not hold secrets.
+type Sessions struct {
+	secret []byte
+	ttl    time.Duration
+	path   string
+	now    func() time.Time
+}
+
+type sessionPayload struct {
+	Identity server.Identity `json:"id"`
+	Expires  int64           `json:"exp"`
+}
+
+// NewSessions creates a session store signing cookies with secret. Cookies
+// are scoped to pathPrefix.
+func NewSessions(secret string, ttl time.Duration, pathPrefix string) (*Sessions, error) {
+	if len(secret) < minSecretLength {
+		return nil, errors.New("session secret must be at least 32 bytes")
+	}
+	if ttl <= 0 {
+		ttl = DefaultSessionTTL
+	}
+	return &Sessions{secret: []byte(secret), ttl: ttl, path: cookiePath(pathPrefix), now: time.Now}, nil
+}
+
+// Authenticate returns the identity of a valid session cookie.
+func (s *Sessions) Authenticate(r *http.Request) (server.Identity, bool) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return server.Identity{}, false
+	}
+	var payload sessionPayload
+	if !s.decode(SessionCookie, cookie.Value, &payload) || s.now().Unix() >= payload.Expires {
+		return server.Identity{}, false
+	}
+	return payload.Identity, payload.Identity.Subject != ""
+}
+
+// Start sets the session cookie for id.
+func (s *Sessions) Start(w http.ResponseWriter, r *http.Request, id server.Identity) error {
+	expires := s.now().Add(s.ttl)
+	value, err := s.encode(SessionCookie, sessionPayload{Identity: id, Expires: expires.Unix()})
+	if err != nil {
+		return err
+	}
+	s.setCookie(w, r, SessionCookie, value, expires)
+	return nil
+}
+
+// End clears the session cookie.
+func (s *Sessions) End(w http.ResponseWriter, r *http.Request) {
+	s.setCookie(w, r, SessionCookie, "", time.Unix(0, 0))
+}
+
+func (s *Sessions) setCookie(w http.ResponseWriter, r *http.Request, name, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.path,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// encode serializes v and signs it with HMAC-SHA256. The cookie name is
+// part of the signature so that one cookie cannot be replayed as another.
+func (s *Sessions) encode(name string, v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(data)
+	return body + "." + s.sign(name, body), nil
+}
+
+func (s *Sessions) decode(name, value string, v any) bool {
+	body, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(name, body))) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func (s *Sessions) sign(name, body string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(name + "\x00" + body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func cookiePath(pathPrefix string) string {
+	pathPrefix = strings.Trim(strings.TrimSpace(pathPrefix), "/")
+	if pathPrefix == "" {
+		return "/"
+	}
+	return "/" + pathPrefix + "/"
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/server"
+)
+
+func TestSessions(t *testing.T) {
+	if _, err := NewSessions("short", 0, ""); err == nil {
+		t.Fatalf("expected short secret to be rejected")
+	}
+
+	sessions, err := NewSessions(testSecret, time.Hour, "/admin/")
+	if err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	sessions.now = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	id := server.Identity{Subject: "user-1", Roles: []string{"admin"}}
+	if err := sessions.Start(rec, httptest.NewRequest(http.MethodGet, "https://example.com/admin/", nil), id); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	cookie := rec.Result().Cookies()[0]
+	if cookie.Name != SessionCookie || cookie.Path != "/admin/" || !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("unexpected cookie attributes: %+v", cookie)
+	}
+
+	authenticate := func(value string) (server.Identity, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/api/me", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookie, Value: value})
+		return sessions.Authenticate(req)
+	}
+
+	if got, ok := authenticate(cookie.Value); !ok || !got.HasRole("admin") {
+		t.Fatalf("expected valid session, got %+v %v", got, ok)
+	}
+	if _, ok := authenticate(cookie.Value[1:]); ok {
+		t.Fatalf("expected tampered session to be rejected")
+	}
+
+	other, _ := NewSessions("fedcba9876543210fedcba9876543210", time.Hour, "/admin/")
+	if _, ok := other.Authenticate(&http.Request{Header: http.Header{"Cookie": {cookie.String()}}}); ok {
+		t.Fatalf("expected session signed with another secret to be rejected")
+	}
+
+	login, _ := sessions.encode(loginCookie, loginState{State: "s", Expires: now.Add(time.Hour).Unix()})
+	if _, ok := authenticate(login); ok {
+		t.Fatalf("expected login cookie to be rejected as a session")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok := authenticate(cookie.Value); ok {
+		t.Fatalf("expected expired session to be rejected")
+	}
+}
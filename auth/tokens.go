@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/server"
+)
+
+const (
+	hashPrefix  = "sha256:"
+	tokenPrefix = "rmn_"
+)
+
+// TokenStore looks up API tokens by their hash, see HashToken.
+type TokenStore interface {
+	LookupToken(hash string) (config.APIToken, bool)
+}
+
+// GenerateToken returns a new random API token and the hash to configure
+// for it.
+func GenerateToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the configured form of token: "sha256:<hex digest>".
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// StaticTokens is a fixed set of tokens keyed by hash.
+type StaticTokens map[string]config.APIToken
+
+// NewStaticTokens validates tokens and indexes them by hash.
+func NewStaticTokens(tokens []config.APIToken) (StaticTokens, error) {
+	store := make(StaticTokens, len(tokens))
+	for i, token := range tokens {
+		hash := strings.ToLower(strings.TrimSpace(token.Hash))
+		digest, ok := strings.CutPrefix(hash, hashPrefix)
+		if _, err := hex.DecodeString(digest); !ok || err != nil || len(digest) != sha256.Size*2 {
+			return nil, fmt.Errorf("token %d (%s): hash must be sha256:<hex>", i, token.Name)
+		}
+		if token.Name == "" && token.Subject == "" {
+			return nil, fmt.Errorf("token %d: name or subject is required", i)
+		}
+		if _, ok := store[hash]; ok {
+			return nil, fmt.Errorf("token %d (%s): duplicate hash", i, token.Name)
+		}
+		for _, scope := range token.Scopes {
+			switch scope {
+			case server.ReadScope, server.WriteScope, server.AdminScope:
+			default:
+				return nil, fmt.Errorf("token %d (%s): unknown scope %q", i, token.Name, scope)
+			}
+		}
+		store[hash] = token
+	}
+	return store, nil
+}
+
+func (s StaticTokens) LookupToken(hash string) (config.APIToken, bool) {
+	token, ok := s[hash]
+	return token, ok
+}
+
+// FileTokens reads tokens from a YAML file with a top-level "tokens" list
+// and reloads it when the file changes. If the file becomes unreadable or
+// invalid, no token is accepted until it is fixed.
+type FileTokens struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tokens  StaticTokens
+}
+
+// NewFileTokens loads the token file at path.
+func NewFileTokens(path string) (*FileTokens, error) {
+	f := &FileTokens{path: path}
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileTokens) LookupToken(hash string) (config.APIToken, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if info, err := os.Stat(f.path); err != nil {
+		f.tokens = nil
+	} else if !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+		_ = f.reload()
+	}
+	return f.tokens.LookupToken(hash)
+}
+
+func (f *FileTokens) reload() error {
+	f.tokens = nil
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("token file: %w", err)
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("token file: %w", err)
+	}
+	var doc struct {
+		Tokens []config.APIToken `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("token file %s: %w", f.path, err)
+	}
+	tokens, err := NewStaticTokens(doc.Tokens)
+	if err != nil {
+		return fmt.Errorf("token file %s: %w", f.path, err)
+	}
+	f.tokens = tokens
+	return nil
+}
+
+// Tokens authenticates requests carrying an API token in an
+// "Authorization: Bearer" header.
+type Tokens struct {
+	stores []TokenStore
+	now    func() time.Time
+}
+
+// NewTokens accepts tokens found in any of stores.
+func NewTokens(stores ...TokenStore) (*Tokens, error) {
+	if len(stores) == 0 {
+		return nil, errors.New("api tokens require a token store")
+	}
+	return &Tokens{stores: stores, now: time.Now}, nil
+}
+
+// Authenticate returns the identity of a known, unexpired bearer token.
+func (t *Tokens) Authenticate(r *http.Request) (server.Identity, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return server.Identity{}, false
+	}
+
+	hash := HashToken(token)
+	for _, store := range t.stores {
+		apiToken, ok := store.LookupToken(hash)
+		if !ok {
+			continue
+		}
+		if !apiToken.ExpiresAt.IsZero() && !t.now().Before(apiToken.ExpiresAt) {
+			return server.Identity{}, false
+		}
+		return tokenIdentity(apiToken), true
+	}
+	return server.Identity{}, false
+}
+
+func tokenIdentity(token config.APIToken) server.Identity {
+	subject := token.Subject
+	if subject == "" {
+		subject = "token:" + token.Name
+	}
+	scopes := token.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{server.ReadScope}
+	}
+	return server.Identity{
+		Subject: subject,
+		Roles:   token.Roles,
+		Widgets: token.Widgets,
+		Scopes:  scopes,
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/server"
+)
+
+func TestTokens(t *testing.T) {
+	token, hash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	expired, expiredHash, _ := GenerateToken()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store, err := NewStaticTokens([]config.APIToken{
+		{Name: "exports", Hash: strings.ToUpper(hash), Roles: []string{"viewer"}, Widgets: []string{"orders"}, ExpiresAt: now.Add(time.Hour)},
+		{Name: "old", Hash: expiredHash, ExpiresAt: now},
+	})
+	if err != nil {
+		t.Fatalf("static tokens: %v", err)
+	}
+	tokens, err := NewTokens(store)
+	if err != nil {
+		t.Fatalf("tokens: %v", err)
+	}
+	tokens.now = func() time.Time { return now }
+
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"Bearer " + token, true},
+		{"bearer  " + token, true},
+		{"Basic " + token, false},
+		{"Bearer " + token + "x", false},
+		{"Bearer " + expired, false},
+		{"Bearer", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/widgets/orders", nil)
+		req.Header.Set("Authorization", tt.header)
+		id, ok := tokens.Authenticate(req)
+		if ok != tt.ok {
+			t.Fatalf("%q: expected ok=%v, got %v", tt.header, tt.ok, ok)
+		}
+		if ok && (id.Subject != "token:exports" || !id.HasRole("viewer") || !id.CanAccessWidget("orders") || id.CanAccessWidget("users") ||
+			!id.HasScope(server.ReadScope) || id.HasScope(server.WriteScope)) {
+			t.Fatalf("unexpected identity: %+v", id)
+		}
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	admin := tokenIdentity(config.APIToken{Name: "ops", Scopes: []string{server.AdminScope}})
+	if !admin.HasScope(server.ReadScope) || !admin.HasScope(server.WriteScope) || !admin.HasScope(server.AdminScope) {
+		t.Fatalf("expected admin scope to include write and read, got %+v", admin)
+	}
+	write := tokenIdentity(config.APIToken{Name: "sync", Scopes: []string{server.WriteScope}})
+	if !write.HasScope(server.ReadScope) || !write.HasScope(server.WriteScope) || write.HasScope(server.AdminScope) {
+		t.Fatalf("expected write scope to include only read, got %+v", write)
+	}
+	if session := (server.Identity{Subject: "ann"}); !session.HasScope(server.AdminScope) {
+		t.Fatalf("expected identities without scopes to be unrestricted")
+	}
+}
+
+func TestStaticTokensValidation(t *testing.T) {
+	valid := HashToken("secret")
+	for name, tokens := range map[string][]config.APIToken{
+		"plain token": {{Name: "a", Hash: "secret"}},
+		"short hash":  {{Name: "a", Hash: "sha256:abcd"}},
+		"no name":     {{Hash: valid}},
+		"duplicate":   {{Name: "a", Hash: valid}, {Name: "b", Hash: valid}},
+		"bad scope":   {{Name: "a", Hash: valid, Scopes: []string{"delete"}}},
+	} {
+		if _, err := NewStaticTokens(tokens); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestFileTokensReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write tokens: %v", err)
+		}
+	}
+
+	write("tokens:\n  - name: cron\n    hash: " + HashToken("one") + "\n")
+	store, err := NewFileTokens(path)
+	if err != nil {
+		t.Fatalf("file tokens: %v", err)
+	}
+	if _, ok := store.LookupToken(HashToken("one")); !ok {
+		t.Fatalf("expected token from file")
+	}
+
+	write("tokens:\n  - name: cron-rotated\n    hash: " + HashToken("two") + "\n")
+	if _, ok := store.LookupToken(HashToken("one")); ok {
+		t.Fatalf("expected revoked token to be rejected after reload")
+	}
+	if token, ok := store.LookupToken(HashToken("two")); !ok || token.Name != "cron-rotated" {
+		t.Fatalf("expected rotated token after reload")
+	}
+
+	write("tokens: [")
+	if _, ok := store.LookupToken(HashToken("two")); ok {
+		t.Fatalf("expected invalid file to reject all tokens")
+	}
+}
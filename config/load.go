@@ -27,6 +27,18 @@ func Load(path string) (AppConfig, error) {
 	if err := resolveProviderEnv(&cfg); err != nil {
 		return cfg, err
 	}
+	if err := resolveAuthEnv(cfg.Auth); err != nil {
+		return cfg, err
+	}
+	if err := resolveActionEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := resolveMaskEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
 
 	return cfg, nil
 }
@@ -57,6 +69,83 @@ func resolveProviderConfigEnv(provider *ProviderConfig) error {
 	return nil
 }
 
+func resolveAuthEnv(auth *AuthConfig) error {
+	if auth == nil {
+		return nil
+	}
+
+	var err error
+	auth.SessionSecret, err = resolveEnvValue(auth.SessionSecret)
+	if err != nil {
+		return fmt.Errorf("auth session_secret: %w", err)
+	}
+	if auth.OIDC != nil {
+		for name, value := range map[string]*string{
+			"issuer":        &auth.OIDC.Issuer,
+			"client_id":     &auth.OIDC.ClientID,
+			"client_secret": &auth.OIDC.ClientSecret,
+			"redirect_url":  &auth.OIDC.RedirectURL,
+		} {
+			*value, err = resolveEnvValue(*value)
+			if err != nil {
+				return fmt.Errorf("auth oidc %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveActionEnv resolves webhook URLs and headers, which often carry
+// credentials.
+func resolveActionEnv(cfg *AppConfig) error {
+	for _, page := range cfg.Pages {
+		for _, widget := range page.Widgets {
+			if widget.Table == nil {
+				continue
+			}
+			for _, action := range widget.Table.RowActions {
+				if action.Webhook == nil {
+					continue
+				}
+				var err error
+				action.Webhook.URL, err = resolveEnvValue(action.Webhook.URL)
+				if err != nil {
+					return fmt.Errorf("widget %s action %s url: %w", widget.ID, action.ID, err)
+				}
+				for name, value := range action.Webhook.Headers {
+					action.Webhook.Headers[name], err = resolveEnvValue(value)
+					if err != nil {
+						return fmt.Errorf("widget %s action %s header %s: %w", widget.ID, action.ID, name, err)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveMaskEnv resolves the keys of hash masks.
+func resolveMaskEnv(cfg *AppConfig) error {
+	for _, page := range cfg.Pages {
+		for _, widget := range page.Widgets {
+			if widget.Table == nil {
+				continue
+			}
+			for _, column := range widget.Table.Columns {
+				if column.Mask == nil {
+					continue
+				}
+				var err error
+				column.Mask.HashKey, err = resolveEnvValue(column.Mask.HashKey)
+				if err != nil {
+					return fmt.Errorf("widget %s column %s hash_key: %w", widget.ID, column.ID, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func resolveEnvValue(value string) (string, error) {
 	if !envPattern.MatchString(value) {
 		return value, nil
@@ -1,6 +1,12 @@
 package config
 
-import "gopkg.in/yaml.v3"
+import (
+	"regexp"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 const (
 	EqOperator       FilterOperator = "eq"
@@ -14,28 +20,222 @@ const (
 )
 
 const (
-	JsonArray DataType = "json_array"
+	JsonArray  DataType = "json_array"
+	JsonObject DataType = "json_object"
+	// UnixTime marks integer Unix timestamps (seconds), e.g. for chart time
+	// buckets.
+	UnixTime DataType = "unix_time"
+)
+
+const (
+	LikeSearchEngine     SearchEngine = "like"
+	FTS5SearchEngine     SearchEngine = "fts5"
+	TSVectorSearchEngine SearchEngine = "tsvector"
+)
+
+const (
+	FullMask    MaskType = "full"
+	PartialMask MaskType = "partial"
+	Last4Mask   MaskType = "last4"
+	HashMask    MaskType = "hash"
+)
+
+const (
+	DateFormat     FormatType = "date"
+	NumberFormat   FormatType = "number"
+	CurrencyFormat FormatType = "currency"
+	EnumFormat     FormatType = "enum"
+	BytesFormat    FormatType = "bytes"
+	DurationFormat FormatType = "duration"
+	TemplateFormat FormatType = "template"
+)
+
+const (
+	LinkRender     = "link"
+	BadgeRender    = "badge"
+	BooleanRender  = "boolean"
+	ImageRender    = "image"
+	JSONRender     = "json"
+	DateTimeRender = "datetime"
+)
+
+// BadgeColors are the colors a badge render can use besides #rrggbb values.
+var BadgeColors = []string{"gray", "red", "orange", "yellow", "green", "teal", "blue", "purple"}
+
+const (
+	TableWidget = "table"
+	StatWidget  = "stat"
+	ChartWidget = "chart"
+)
+
+const (
+	LineChart = "line"
+	BarChart  = "bar"
+	PieChart  = "pie"
+)
+
+const (
+	HourBucket  TimeBucket = "hour"
+	DayBucket   TimeBucket = "day"
+	WeekBucket  TimeBucket = "week"
+	MonthBucket TimeBucket = "month"
+)
+
+const (
+	SumAggregation   Aggregation = "sum"
+	CountAggregation Aggregation = "count"
+	AvgAggregation   Aggregation = "avg"
+	MinAggregation   Aggregation = "min"
+	MaxAggregation   Aggregation = "max"
 )
 
 type FilterOperator string
 
 type DataType string
 
+type SearchEngine string
+
+type Aggregation string
+
+type TimeBucket string
+
+type MaskType string
+
+type FormatType string
+
 type AppConfig struct {
 	Title      string                    `yaml:"title" json:"title"`
 	PathPrefix string                    `yaml:"path_prefix" json:"path_prefix,omitempty"`
 	Providers  map[string]ProviderConfig `yaml:"providers" json:"-"`
 	Menu       []MenuItem                `yaml:"menu" json:"menu"`
 	Pages      []Page                    `yaml:"pages" json:"pages"`
+	Audit      *AuditConfig              `yaml:"audit" json:"-"`
+	RateLimit  *RateLimitConfig          `yaml:"rate_limit" json:"-"`
+	Security   *SecurityConfig           `yaml:"security" json:"-"`
+	CORS       *CORSConfig               `yaml:"cors" json:"-"`
+	Auth       *AuthConfig               `yaml:"auth" json:"-"`
+}
+
+// AuthConfig enables authentication. Once configured, the API only serves
+// authenticated users.
+type AuthConfig struct {
+	// SessionSecret signs session cookies; it must be at least 32 bytes.
+	SessionSecret string        `yaml:"session_secret"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+	OIDC          *OIDCConfig   `yaml:"oidc"`
+	// Tokens are API tokens for programmatic access. TokensFile holds more
+	// tokens in the same format and is reloaded when it changes.
+	Tokens     []APIToken `yaml:"tokens"`
+	TokensFile string     `yaml:"tokens_file"`
+	// AdminRoles may use admin endpoints such as cache purges; "admin" by
+	// default.
+	AdminRoles []string `yaml:"admin_roles"`
+}
+
+// APIToken is a bearer token known only by its hash.
+type APIToken struct {
+	Name string `yaml:"name"`
+	// Hash is "sha256:" followed by the hex SHA-256 digest of the token.
+	Hash string `yaml:"hash"`
+	// Subject identifies the token's user; it defaults to "token:<name>".
+	Subject string   `yaml:"subject"`
+	Roles   []string `yaml:"roles"`
+	// Widgets restricts the token to these widget IDs; empty allows all.
+	Widgets []string `yaml:"widgets"`
+	// Scopes grant "read", "write" (row actions) or "admin" (admin
+	// endpoints); each includes the ones before it. Empty grants read.
+	Scopes    []string  `yaml:"scopes"`
+	ExpiresAt time.Time `yaml:"expires_at"`
+}
+
+// OIDCConfig configures login through an OpenID Connect provider.
+type OIDCConfig struct {
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+	// RedirectURL defaults to <path_prefix>/auth/callback on the request's
+	// host.
+	RedirectURL string   `yaml:"redirect_url"`
+	Scopes      []string `yaml:"scopes"`
+	// RolesClaim names the claim holding groups or roles, e.g. "groups".
+	RolesClaim string `yaml:"roles_claim"`
+	// RoleMapping maps claim values to Rapidmin roles. Unmapped values are
+	// ignored; without a mapping the claim values are used as roles.
+	RoleMapping map[string]string `yaml:"role_mapping"`
+	// Attributes maps user attribute names to claim names.
+	Attributes map[string]string `yaml:"attributes"`
+}
+
+// CORSConfig lets browser apps on other origins call the API.
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://tools.example.com"; "*"
+	// allows any origin and cannot be combined with AllowCredentials.
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// SecurityConfig tunes security headers and CSRF checks. Empty values use
+// secure defaults; "-" omits a header.
+type SecurityConfig struct {
+	// ContentSecurityPolicy may contain {{inline_hashes}}, which expands to
+	// the hashes of the script and style inlined in the UI.
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	FrameOptions          string `yaml:"frame_options"`
+	ReferrerPolicy        string `yaml:"referrer_policy"`
+	// HSTSMaxAge is sent on HTTPS requests; a negative value disables HSTS.
+	HSTSMaxAge time.Duration `yaml:"hsts_max_age"`
+	// TrustedOrigins may send state-changing requests besides the server's
+	// own origin, e.g. "https://tools.example.com". CORS allowed origins are
+	// not trusted unless listed here.
+	TrustedOrigins []string `yaml:"trusted_origins"`
+}
+
+// RateLimitConfig limits widget data requests with token buckets. Every
+// request takes a token from the global bucket, from the bucket of its widget
+// and from the bucket of its user; anonymous users are keyed by address.
+type RateLimitConfig struct {
+	Global    *RateSpec `yaml:"global"`
+	PerWidget *RateSpec `yaml:"per_widget"`
+	PerUser   *RateSpec `yaml:"per_user"`
+}
+
+// RateSpec is a token bucket refilled with Rate tokens per second and holding
+// at most Burst tokens.
+type RateSpec struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// AuditConfig enables the audit log of data access. Events are appended to a
+// JSON-lines file, inserted into a table of a SQL provider's database, or
+// both.
+type AuditConfig struct {
+	File      string `yaml:"file"`
+	Provider  string `yaml:"provider"`
+	Table     string `yaml:"table"`
+	QueueSize int    `yaml:"queue_size"`
 }
 
 type ProviderConfig struct {
 	SQL *SQLProviderConfig `yaml:"sql" json:"-"`
+	// MaxInFlight caps concurrent widget requests served by the provider.
+	MaxInFlight int `yaml:"max_in_flight" json:"-"`
 }
 
 type SQLProviderConfig struct {
-	Driver string `yaml:"driver" json:"-"`
-	DSN    string `yaml:"dsn" json:"-"`
+	Driver          string        `yaml:"driver" json:"-"`
+	DSN             string        `yaml:"dsn" json:"-"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" json:"-"`
+	MaxOpenConns    int           `yaml:"max_open_conns" json:"-"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" json:"-"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" json:"-"`
+	// LogQueries is the slog level (debug, info, warn) queries are logged
+	// at; queries are not logged when empty.
+	LogQueries string `yaml:"log_queries" json:"-"`
 }
 
 type MenuItem struct {
@@ -46,9 +246,10 @@ type MenuItem struct {
 }
 
 type Page struct {
-	Slug    string   `yaml:"slug" json:"slug"`
-	Title   string   `yaml:"title" json:"title"`
-	Widgets []Widget `yaml:"widgets" json:"widgets"`
+	Slug    string       `yaml:"slug" json:"slug"`
+	Title   string       `yaml:"title" json:"title"`
+	Filters []FilterSpec `yaml:"filters" json:"filters,omitempty"`
+	Widgets []Widget     `yaml:"widgets" json:"widgets"`
 }
 
 type Widget struct {
@@ -57,6 +258,43 @@ type Widget struct {
 	Type     string       `yaml:"type" json:"type"`
 	Provider ProviderSpec `yaml:"provider" json:"-"` // exclude from /api/config response for security reasons
 	Table    *TableSpec   `yaml:"table" json:"table,omitempty"`
+	Stat     *StatSpec    `yaml:"stat" json:"stat,omitempty"`
+	Chart    *ChartSpec   `yaml:"chart" json:"chart,omitempty"`
+	// PageFilters maps page filter IDs to targets of this widget's query.
+	// Page filters without a mapping use their own target; an empty
+	// target excludes the widget from that filter.
+	PageFilters map[string]string `yaml:"page_filters" json:"page_filters,omitempty"`
+	Cache       *CacheSpec        `yaml:"cache" json:"-"`
+	// ETag enables conditional requests for the widget's data.
+	ETag bool `yaml:"etag" json:"-"`
+	// RateLimit overrides rate_limit.per_widget for this widget.
+	RateLimit *RateSpec `yaml:"rate_limit" json:"-"`
+}
+
+// CacheSpec enables caching of the widget's provider responses for TTL.
+type CacheSpec struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// FilterSpecs returns the filters declared for the widget's type.
+func (w Widget) FilterSpecs() []FilterSpec {
+	switch {
+	case w.Table != nil:
+		return w.Table.Filters
+	case w.Stat != nil:
+		return w.Stat.Filters
+	case w.Chart != nil:
+		return w.Chart.Filters
+	}
+	return nil
+}
+
+// PaginationColumn returns the column of the widget's SQL cursor, if any.
+func (w Widget) PaginationColumn() string {
+	if w.Provider.SQL == nil || w.Provider.SQL.Pagination == nil {
+		return ""
+	}
+	return w.Provider.SQL.Pagination.Column
 }
 
 type ProviderSpec struct {
@@ -69,6 +307,10 @@ type SQLSpec struct {
 	Bindings   map[string]string   `yaml:"bindings" json:"bindings"`
 	Types      map[string]DataType `yaml:"types" json:"types,omitempty"`
 	Pagination *PaginationSpec     `yaml:"pagination" json:"pagination,omitempty"`
+	Timeout    time.Duration       `yaml:"timeout" json:"timeout,omitempty"`
+	// LogArgs lists columns whose filter values may appear in query logs;
+	// values of all other columns are redacted.
+	LogArgs []string `yaml:"log_args" json:"log_args,omitempty"`
 }
 
 type PaginationSpec struct {
@@ -77,21 +319,250 @@ type PaginationSpec struct {
 }
 
 type TableSpec struct {
-	Columns []ColumnSpec `yaml:"columns" json:"columns"`
-	Filters []FilterSpec `yaml:"filters" json:"filters,omitempty"`
+	Columns    []ColumnSpec `yaml:"columns" json:"columns"`
+	Filters    []FilterSpec `yaml:"filters" json:"filters,omitempty"`
+	Search     *SearchSpec  `yaml:"search" json:"search,omitempty"`
+	RowActions []RowAction  `yaml:"row_actions" json:"row_actions,omitempty"`
+	// RowKey is the column that identifies a row for row actions; "id" by
+	// default.
+	RowKey string `yaml:"row_key" json:"row_key,omitempty"`
+}
+
+// RowKeyColumn returns the column that identifies table rows.
+func (t TableSpec) RowKeyColumn() string {
+	if t.RowKey == "" {
+		return "id"
+	}
+	return t.RowKey
+}
+
+// RowAction is an operator button on every table row, such as "Refund". It
+// runs either a SQL statement or a webhook call on the row loaded by its key
+// through the widget's query. Statements bind row values as :row.<column>
+// and parameters as :param.<id>; webhook URLs use {{row.<column>}} and
+// {{param.<id>}}.
+type RowAction struct {
+	ID    string `yaml:"id" json:"id"`
+	Label string `yaml:"label" json:"label"`
+	// Confirm is shown before running the action and may use {{column}}
+	// templates. Requests for actions with Confirm must be confirmed.
+	Confirm string `yaml:"confirm" json:"confirm,omitempty"`
+	// Roles may run the action; empty allows every user.
+	Roles   []string       `yaml:"roles" json:"roles,omitempty"`
+	Params  []ActionParam  `yaml:"params" json:"params,omitempty"`
+	SQL     *ActionSQL     `yaml:"sql" json:"-"`
+	Webhook *ActionWebhook `yaml:"webhook" json:"-"`
+}
+
+// ActionParam is an input asked from the operator when running an action.
+// Type is text (default), number or select_one with Values.
+type ActionParam struct {
+	ID       string        `yaml:"id" json:"id"`
+	Title    string        `yaml:"title" json:"title"`
+	Type     string        `yaml:"type" json:"type,omitempty"`
+	Required bool          `yaml:"required" json:"required,omitempty"`
+	Values   []ValueOption `yaml:"values" json:"values,omitempty"`
+}
+
+// ActionSQL runs Statement on Provider, the widget's provider by default.
+type ActionSQL struct {
+	Provider  string `yaml:"provider"`
+	Statement string `yaml:"statement"`
+}
+
+var bindingPattern = regexp.MustCompile(`(?:^|[^:]):((?:row|param)\.[A-Za-z0-9_]+)`)
+
+// Bindings returns the row and parameter names the statement binds, such
+// as "row.id" or "param.reason", in order of first use.
+func (a ActionSQL) Bindings() []string {
+	var names []string
+	for _, match := range bindingPattern.FindAllStringSubmatch(a.Statement, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// ActionWebhook sends the action as JSON to URL. Any 2xx response counts as
+// success.
+type ActionWebhook struct {
+	URL     string            `yaml:"url"`
+	Method  string            `yaml:"method"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// SearchSpec enables a single full-text search box for a table widget.
+// Engine defaults to case-insensitive LIKE matching across Columns;
+// fts5 matches Key against the rowid of the SQLite FTS5 table Table,
+// tsvector uses Postgres text search with the Language configuration.
+type SearchSpec struct {
+	Columns     []string     `yaml:"columns" json:"columns"`
+	Placeholder string       `yaml:"placeholder" json:"placeholder,omitempty"`
+	Engine      SearchEngine `yaml:"engine" json:"-"`
+	Table       string       `yaml:"table" json:"-"`
+	Key         string       `yaml:"key" json:"-"`
+	Language    string       `yaml:"language" json:"-"`
+}
+
+// StatSpec describes a KPI card showing a single number. Value, Label, Delta
+// and Previous name columns of the query result. Without Aggregate the query
+// must return one row; with Aggregate the value (and previous) columns are
+// aggregated over the filtered query rows. Previous is a comparison value
+// computed by the query itself; no previous period is derived from the
+// filters. Delta is computed from Previous when no Delta column is given.
+type StatSpec struct {
+	Value     string       `yaml:"value" json:"value,omitempty"`
+	Label     string       `yaml:"label" json:"label,omitempty"`
+	Delta     string       `yaml:"delta" json:"delta,omitempty"`
+	Previous  string       `yaml:"previous" json:"previous,omitempty"`
+	Aggregate Aggregation  `yaml:"aggregate" json:"aggregate,omitempty"`
+	Format    *StatFormat  `yaml:"format" json:"format,omitempty"`
+	Filters   []FilterSpec `yaml:"filters" json:"filters,omitempty"`
+}
+
+// ValueColumn returns the result column holding the stat value.
+func (s StatSpec) ValueColumn() string {
+	if s.Value == "" || s.Value == "*" {
+		return "value"
+	}
+	return s.Value
+}
+
+// StatFormat holds number formatting hints for the UI.
+type StatFormat struct {
+	Style    string `yaml:"style" json:"style,omitempty"` // number, percent or currency
+	Currency string `yaml:"currency" json:"currency,omitempty"`
+	Decimals *int   `yaml:"decimals" json:"decimals,omitempty"`
+	Prefix   string `yaml:"prefix" json:"prefix,omitempty"`
+	Suffix   string `yaml:"suffix" json:"suffix,omitempty"`
+	Compact  bool   `yaml:"compact" json:"compact,omitempty"`
+}
+
+// ChartSpec describes a line, bar or pie chart. Rows of the query are grouped
+// by Dimension, optionally truncated to a time Bucket, and every measure is
+// aggregated per group. Limit keeps the groups with the largest first
+// measure.
+type ChartSpec struct {
+	Kind      string        `yaml:"kind" json:"kind"`
+	Dimension string        `yaml:"dimension" json:"dimension"`
+	Bucket    TimeBucket    `yaml:"bucket" json:"bucket,omitempty"`
+	Measures  []MeasureSpec `yaml:"measures" json:"measures"`
+	Limit     int           `yaml:"limit" json:"limit,omitempty"`
+	Filters   []FilterSpec  `yaml:"filters" json:"filters,omitempty"`
+}
+
+type MeasureSpec struct {
+	ID        string      `yaml:"id" json:"id,omitempty"`
+	Title     string      `yaml:"title" json:"title,omitempty"`
+	Column    string      `yaml:"column" json:"column,omitempty"`
+	Aggregate Aggregation `yaml:"aggregate" json:"aggregate"`
+}
+
+// Key returns the result column of the measure: its ID or, when omitted,
+// the aggregation and column joined by an underscore.
+func (m MeasureSpec) Key() string {
+	if m.ID != "" {
+		return m.ID
+	}
+	if m.Column == "" || m.Column == "*" {
+		return string(m.Aggregate)
+	}
+	return string(m.Aggregate) + "_" + m.Column
 }
 
 type ColumnSpec struct {
 	ID     string        `yaml:"id" json:"id"`
 	Title  string        `yaml:"title" json:"title,omitempty"`
 	Render *ColumnRender `yaml:"render" json:"render,omitempty"`
+	Mask   *MaskSpec     `yaml:"mask" json:"mask,omitempty"`
+	Format *ColumnFormat `yaml:"format" json:"format,omitempty"`
+}
+
+// ColumnFormat formats column values on the server, after masking. A
+// template column may use an ID not returned by the query. Values that
+// cannot be formatted are left unchanged. A scalar such as `format: bytes`
+// sets only the type.
+type ColumnFormat struct {
+	Type FormatType `yaml:"type" json:"type"`
+	// Layout is a Go time layout for dates, "2006-01-02 15:04" by default;
+	// TimeZone is an IANA zone name, UTC by default.
+	Layout   string `yaml:"layout" json:"-"`
+	TimeZone string `yaml:"time_zone" json:"-"`
+	// Decimals and Thousands (a group separator) apply to numbers and
+	// currencies; Currency is an ISO 4217 code such as "EUR".
+	Decimals  *int   `yaml:"decimals" json:"-"`
+	Thousands string `yaml:"thousands" json:"-"`
+	Currency  string `yaml:"currency" json:"-"`
+	// Labels maps enum values to labels; unknown values are kept.
+	Labels map[string]string `yaml:"labels" json:"-"`
+	// Unit of duration values: ms, s (default), m or h.
+	Unit string `yaml:"unit" json:"-"`
+	// Template combines the row's values, e.g. "{{first}} {{last}}".
+	Template string `yaml:"template" json:"-"`
+}
+
+// MaskSpec hides column values from users without one of ExemptRoles.
+// Masked columns cannot be filtered or searched by those users. A scalar
+// such as `mask: last4` sets only the type.
+type MaskSpec struct {
+	Type        MaskType `yaml:"type" json:"type"`
+	ExemptRoles []string `yaml:"exempt_roles" json:"-"`
+	// HashKey keys the HMAC of hash masks so that pseudonyms cannot be
+	// reversed by hashing candidate values.
+	HashKey string `yaml:"hash_key" json:"-"`
+}
+
+func (m *MaskSpec) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		m.Type = MaskType(n.Value)
+		return nil
+	}
+
+	type raw MaskSpec
+	var parsed raw
+	if err := n.Decode(&parsed); err != nil {
+		return err
+	}
+	*m = MaskSpec(parsed)
+	return nil
 }
 
+// ColumnRender tells the UI how to display a column. Text and URL are
+// templates over the row, e.g. "/users/{{id}}"; link uses both, image uses
+// URL for the thumbnail and Text for its alt text.
 type ColumnRender struct {
 	Type     string `yaml:"type" json:"type"`
 	Text     string `yaml:"text" json:"text,omitempty"`
 	URL      string `yaml:"url" json:"url,omitempty"`
 	External bool   `yaml:"external" json:"external,omitempty"`
+	// Colors maps badge values to a color name or #rrggbb; Default colors
+	// the other values.
+	Colors  map[string]string `yaml:"colors" json:"colors,omitempty"`
+	Default string            `yaml:"default" json:"default,omitempty"`
+	// Width and Height bound image thumbnails, in pixels.
+	Width  int `yaml:"width" json:"width,omitempty"`
+	Height int `yaml:"height" json:"height,omitempty"`
+	// Collapsed starts json views folded.
+	Collapsed bool `yaml:"collapsed" json:"collapsed,omitempty"`
+	// Relative shows datetimes as "3 hours ago", with the full time on hover.
+	Relative bool `yaml:"relative" json:"relative,omitempty"`
+}
+
+func (f *ColumnFormat) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		f.Type = FormatType(n.Value)
+		return nil
+	}
+
+	type raw ColumnFormat
+	var parsed raw
+	if err := n.Decode(&parsed); err != nil {
+		return err
+	}
+	*f = ColumnFormat(parsed)
+	return nil
 }
 
 func (c *ColumnSpec) UnmarshalYAML(n *yaml.Node) error {
@@ -115,12 +586,21 @@ func (c *ColumnSpec) UnmarshalYAML(n *yaml.Node) error {
 }
 
 type FilterSpec struct {
-	ID        string           `yaml:"id" json:"id"`
-	Title     string           `yaml:"title" json:"title"`
-	Type      string           `yaml:"type" json:"type"`
-	Target    string           `yaml:"target" json:"target"`
-	Operators []FilterOperator `yaml:"operators" json:"operators,omitempty"`
-	Values    []ValueOption    `yaml:"values" json:"values,omitempty"`
+	ID         string            `yaml:"id" json:"id"`
+	Title      string            `yaml:"title" json:"title"`
+	Type       string            `yaml:"type" json:"type"`
+	Target     string            `yaml:"target" json:"target"`
+	Operators  []FilterOperator  `yaml:"operators" json:"operators,omitempty"`
+	Values     []ValueOption     `yaml:"values" json:"values,omitempty"`
+	ValuesFrom *ValuesSourceSpec `yaml:"values_from" json:"values_from,omitempty"`
+}
+
+// ValuesSourceSpec loads filter value options from a provider query instead
+// of a static list. The query must return value and label columns.
+type ValuesSourceSpec struct {
+	Provider ProviderSpec  `yaml:"provider" json:"-"`
+	Limit    int           `yaml:"limit" json:"limit,omitempty"`
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"-"`
 }
 
 type ValueOption struct {
@@ -0,0 +1,216 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate checks settings that would otherwise only fail, or silently fall
+// back, while serving requests.
+func (c AppConfig) Validate() error {
+	var errs []error
+	if c.CORS != nil && c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		errs = append(errs, errors.New(`cors: allowed_origins "*" cannot be combined with allow_credentials`))
+	}
+	for _, page := range c.Pages {
+		for _, widget := range page.Widgets {
+			if widget.Table == nil {
+				continue
+			}
+			var types map[string]DataType
+			if widget.Provider.SQL != nil {
+				types = widget.Provider.SQL.Types
+			}
+			for _, column := range widget.Table.Columns {
+				if err := column.validate(types); err != nil {
+					errs = append(errs, fmt.Errorf("widget %s column %s: %w", widget.ID, column.ID, err))
+				}
+			}
+			if pagination := widget.PaginationColumn(); pagination != "" {
+				for _, column := range widget.Table.Columns {
+					if column.ID == pagination && column.Mask != nil {
+						errs = append(errs, fmt.Errorf("widget %s: pagination column %s cannot be masked", widget.ID, pagination))
+					}
+				}
+			}
+			if len(widget.Table.RowActions) > 0 {
+				key := widget.Table.RowKeyColumn()
+				for _, column := range widget.Table.Columns {
+					if column.ID == key && column.Mask != nil {
+						errs = append(errs, fmt.Errorf("widget %s: row key %s cannot be masked", widget.ID, key))
+					}
+				}
+			}
+			seen := map[string]bool{}
+			for _, action := range widget.Table.RowActions {
+				if seen[action.ID] {
+					errs = append(errs, fmt.Errorf("widget %s action %s: duplicate id", widget.ID, action.ID))
+				}
+				seen[action.ID] = true
+				if err := action.validate(); err != nil {
+					errs = append(errs, fmt.Errorf("widget %s action %s: %w", widget.ID, action.ID, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c ColumnSpec) validate(types map[string]DataType) error {
+	if c.Mask != nil {
+		switch c.Mask.Type {
+		case FullMask, PartialMask, Last4Mask:
+		case HashMask:
+			if c.Mask.HashKey == "" {
+				return errors.New("hash mask requires hash_key")
+			}
+		default:
+			return fmt.Errorf("unknown mask type %q", c.Mask.Type)
+		}
+	}
+	if c.Format != nil {
+		if err := c.Format.validate(); err != nil {
+			return fmt.Errorf("format: %w", err)
+		}
+	}
+	if c.Render != nil {
+		if err := c.Render.validate(types[c.ID]); err != nil {
+			return fmt.Errorf("render: %w", err)
+		}
+		if c.Render.Type == DateTimeRender && c.Format != nil {
+			return errors.New("render: datetime needs raw values and cannot be combined with a format")
+		}
+	}
+	return nil
+}
+
+func (r ColumnRender) validate(dataType DataType) error {
+	switch r.Type {
+	case LinkRender:
+		if r.URL == "" {
+			return errors.New("link requires a url")
+		}
+	case BadgeRender:
+		for value, color := range r.Colors {
+			if !validBadgeColor(color) {
+				return fmt.Errorf("unknown color %q for %q", color, value)
+			}
+		}
+		if r.Default != "" && !validBadgeColor(r.Default) {
+			return fmt.Errorf("unknown default color %q", r.Default)
+		}
+	case ImageRender:
+		if r.URL == "" {
+			return errors.New("image requires a url")
+		}
+		if r.Width < 0 || r.Height < 0 {
+			return errors.New("image size must not be negative")
+		}
+	case JSONRender:
+		if dataType != JsonArray && dataType != JsonObject {
+			return fmt.Errorf("json requires the column to be typed %s or %s", JsonArray, JsonObject)
+		}
+	case BooleanRender, DateTimeRender:
+	default:
+		return fmt.Errorf("unknown render type %q", r.Type)
+	}
+	return nil
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+func validBadgeColor(color string) bool {
+	return slices.Contains(BadgeColors, color) || hexColor.MatchString(color)
+}
+
+func (f ColumnFormat) validate() error {
+	switch f.Type {
+	case DateFormat:
+		if f.TimeZone != "" {
+			if _, err := time.LoadLocation(f.TimeZone); err != nil {
+				return fmt.Errorf("unknown time zone %q", f.TimeZone)
+			}
+		}
+	case NumberFormat, CurrencyFormat:
+		if f.Decimals != nil && (*f.Decimals < 0 || *f.Decimals > 10) {
+			return fmt.Errorf("decimals must be between 0 and 10")
+		}
+	case EnumFormat:
+		if len(f.Labels) == 0 {
+			return errors.New("enum format requires labels")
+		}
+	case BytesFormat:
+	case DurationFormat:
+		switch f.Unit {
+		case "", "ms", "s", "m", "h":
+		default:
+			return fmt.Errorf("unknown duration unit %q", f.Unit)
+		}
+	case TemplateFormat:
+		if f.Template == "" {
+			return errors.New("template format requires a template")
+		}
+	default:
+		return fmt.Errorf("unknown format type %q", f.Type)
+	}
+	return nil
+}
+
+var webhookParamPattern = regexp.MustCompile(`\{\{\s*param\.([A-Za-z0-9_]+)\s*}}`)
+
+func (a RowAction) validate() error {
+	if a.ID == "" || a.Label == "" {
+		return errors.New("id and label are required")
+	}
+	if (a.SQL == nil) == (a.Webhook == nil) {
+		return errors.New("exactly one of sql and webhook is required")
+	}
+
+	params := map[string]bool{}
+	for _, param := range a.Params {
+		switch param.Type {
+		case "", "text", "number":
+		case "select_one":
+			if len(param.Values) == 0 {
+				return fmt.Errorf("param %s: select_one requires values", param.ID)
+			}
+		default:
+			return fmt.Errorf("param %s: unknown type %q", param.ID, param.Type)
+		}
+		params[param.ID] = true
+	}
+
+	if a.SQL != nil {
+		if a.SQL.Statement == "" {
+			return errors.New("sql requires a statement")
+		}
+		for _, name := range a.SQL.Bindings() {
+			if id, ok := strings.CutPrefix(name, "param."); ok && !params[id] {
+				return fmt.Errorf("statement binds undeclared param %s", id)
+			}
+		}
+	}
+	if a.Webhook != nil {
+		target, err := url.Parse(a.Webhook.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return errors.New("webhook requires an http or https url")
+		}
+		for _, match := range webhookParamPattern.FindAllStringSubmatch(a.Webhook.URL, -1) {
+			if !params[match[1]] {
+				return fmt.Errorf("webhook url uses undeclared param %s", match[1])
+			}
+		}
+		switch strings.ToUpper(a.Webhook.Method) {
+		case "", http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("unsupported webhook method %q", a.Webhook.Method)
+		}
+	}
+	return nil
+}
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ankulikov/rapidmin/config"
 	_ "github.com/mattn/go-sqlite3"
@@ -85,6 +87,9 @@ func startServer() error {
 		return err
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	log.Printf("listening on %s", serverAddr)
-	return http.ListenAndServe(serverAddr, srv.Handler())
+	return srv.ListenAndServe(ctx, serverAddr)
 }
@@ -1,25 +1,87 @@
-import type { AppConfig, DataResponse } from "./types";
+import type { ActionResponse, AppConfig, DataResponse, FilterValuesResponse, Identity, WidgetResponse } from "./types";
 import { withPathPrefix } from "./pathPrefix";
 
+// redirectOnUnauthorized sends the browser to the login when the session
+// has expired.
+function redirectOnUnauthorized(res: Response): void {
+  if (res.status === 401) {
+    const returnTo = window.location.pathname + window.location.search;
+    window.location.assign(withPathPrefix(`/auth/login?${new URLSearchParams({ return_to: returnTo })}`));
+  }
+}
+
+export async function fetchMe(): Promise<Identity | null> {
+  const res = await fetch(withPathPrefix("/api/me"));
+  if (res.status === 401 || res.status === 404) {
+    return null;
+  }
+  if (!res.ok) {
+    throw new Error(`Identity request failed: ${res.status}`);
+  }
+  return res.json();
+}
+
 export async function fetchConfig(): Promise<AppConfig> {
   const res = await fetch(withPathPrefix("/api/config"));
+  redirectOnUnauthorized(res);
   if (!res.ok) {
     throw new Error(`Config request failed: ${res.status}`);
   }
   return res.json();
 }
 
-export async function fetchWidgetData(
+export async function fetchWidgetData<T extends WidgetResponse = DataResponse>(
   widgetId: string,
   params: URLSearchParams,
-): Promise<DataResponse> {
+): Promise<T> {
   const query = params.toString();
   const url = withPathPrefix(
     query ? `/api/widgets/${widgetId}?${query}` : `/api/widgets/${widgetId}`,
   );
   const res = await fetch(url);
+  redirectOnUnauthorized(res);
   if (!res.ok) {
     throw new Error(`Widget request failed: ${res.status}`);
   }
   return res.json();
 }
+
+// fetchFilterValues loads values_from options of a filter owned by source,
+// e.g. "widgets/users" or "pages/overview".
+export async function fetchFilterValues(
+  source: string,
+  filterId: string,
+  search = "",
+): Promise<FilterValuesResponse> {
+  const path = `/api/${source}/filters/${filterId}/values`;
+  const url = withPathPrefix(search ? `${path}?${new URLSearchParams({ q: search })}` : path);
+  const res = await fetch(url);
+  redirectOnUnauthorized(res);
+  if (!res.ok) {
+    throw new Error(`Filter values request failed: ${res.status}`);
+  }
+  return res.json();
+}
+
+// runRowAction runs an action on the row with key. params are the widget's
+// current query params, so the server only finds rows the table shows.
+export async function runRowAction(
+  widgetId: string,
+  actionId: string,
+  params: URLSearchParams,
+  body: { key: unknown; params: Record<string, string>; confirmed: boolean },
+): Promise<ActionResponse> {
+  const query = params.toString();
+  const path = `/api/widgets/${widgetId}/actions/${actionId}`;
+  const res = await fetch(withPathPrefix(query ? `${path}?${query}` : path), {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify(body),
+  });
+  redirectOnUnauthorized(res);
+  const payload = await res.json().catch(() => null);
+  if (!res.ok) {
+    throw new Error(payload?.error?.message ?? `Action request failed: ${res.status}`);
+  }
+  return payload;
+}
@@ -0,0 +1,148 @@
+import React from "react";
+
+import type { ChartResponse, Widget } from "../types";
+
+type Props = {
+  widget: Widget;
+  data: ChartResponse;
+};
+
+const width = 640;
+const height = 260;
+const padding = { top: 16, right: 16, bottom: 36, left: 56 };
+const palette = ["#2563eb", "#f97316", "#16a34a", "#9333ea", "#dc2626", "#0891b2", "#ca8a04", "#db2777"];
+
+export const ChartWidget: React.FC<Props> = ({ widget, data }) => {
+  if (data.labels.length === 0) {
+    return <div className="state">No data.</div>;
+  }
+
+  const kind = widget.chart?.kind ?? "bar";
+  return (
+    <div className="chart">
+      {kind === "pie" ? <PieChart data={data} /> : <XYChart data={data} kind={kind} />}
+      <ul className="chart-legend">
+        {(kind === "pie" ? data.labels.map(String) : data.series.map((series) => series.title)).map(
+          (title, index) => (
+            <li key={`${title}-${index}`}>
+              <span className="chart-swatch" style={{ background: palette[index % palette.length] }} />
+              {title}
+            </li>
+          ),
+        )}
+      </ul>
+    </div>
+  );
+};
+
+const XYChart: React.FC<{ data: ChartResponse; kind: "line" | "bar" }> = ({ data, kind }) => {
+  const values = data.series.flatMap((series) => series.data.map(toNumber));
+  const max = Math.max(0, ...values);
+  const min = Math.min(0, ...values);
+  const range = max - min || 1;
+  const plotWidth = width - padding.left - padding.right;
+  const plotHeight = height - padding.top - padding.bottom;
+  const step = plotWidth / data.labels.length;
+  const y = (value: number) => padding.top + plotHeight - ((value - min) / range) * plotHeight;
+  const x = (index: number) => padding.left + step * index + step / 2;
+  const ticks = [min, min + range / 2, max];
+  const labelEvery = Math.ceil(data.labels.length / 8);
+  const barWidth = (step * 0.8) / Math.max(1, data.series.length);
+
+  return (
+    <svg className="chart-svg" viewBox={`0 0 ${width} ${height}`} role="img">
+      {ticks.map((tick) => (
+        <g key={tick}>
+          <line className="chart-grid" x1={padding.left} x2={width - padding.right} y1={y(tick)} y2={y(tick)} />
+          <text className="chart-axis" x={padding.left - 8} y={y(tick)} textAnchor="end" dominantBaseline="middle">
+            {formatTick(tick)}
+          </text>
+        </g>
+      ))}
+      {data.labels.map((label, index) =>
+        index % labelEvery === 0 ? (
+          <text key={index} className="chart-axis" x={x(index)} y={height - padding.bottom + 18} textAnchor="middle">
+            {String(label)}
+          </text>
+        ) : null,
+      )}
+      {data.series.map((series, seriesIndex) => {
+        const color = palette[seriesIndex % palette.length];
+        if (kind === "line") {
+          const points = series.data.map((value, index) => `${x(index)},${y(toNumber(value))}`).join(" ");
+          return <polyline key={series.id} points={points} fill="none" stroke={color} strokeWidth={2} />;
+        }
+        return (
+          <g key={series.id} fill={color}>
+            {series.data.map((value, index) => {
+              const top = y(Math.max(0, toNumber(value)));
+              const bottom = y(Math.min(0, toNumber(value)));
+              return (
+                <rect
+                  key={index}
+                  x={x(index) - (step * 0.8) / 2 + barWidth * seriesIndex}
+                  y={top}
+                  width={barWidth}
+                  height={Math.max(1, bottom - top)}
+                >
+                  <title>{`${series.title}: ${String(value)}`}</title>
+                </rect>
+              );
+            })}
+          </g>
+        );
+      })}
+    </svg>
+  );
+};
+
+// PieChart draws the first series; pies have a single measure.
+const PieChart: React.FC<{ data: ChartResponse }> = ({ data }) => {
+  const series = data.series[0];
+  const values = (series?.data ?? []).map((value) => Math.max(0, toNumber(value)));
+  const total = values.reduce((sum, value) => sum + value, 0) || 1;
+  const radius = height / 2 - padding.top;
+  const cx = width / 2;
+  const cy = height / 2;
+
+  let angle = -Math.PI / 2;
+  return (
+    <svg className="chart-svg" viewBox={`0 0 ${width} ${height}`} role="img">
+      {values.map((value, index) => {
+        const sweep = (value / total) * Math.PI * 2;
+        const start = angle;
+        angle += sweep;
+        const color = palette[index % palette.length];
+        const title = <title>{`${String(data.labels[index])}: ${String(series.data[index])}`}</title>;
+        if (sweep >= Math.PI * 2 - 1e-9) {
+          return (
+            <circle key={index} cx={cx} cy={cy} r={radius} fill={color}>
+              {title}
+            </circle>
+          );
+        }
+        const largeArc = sweep > Math.PI ? 1 : 0;
+        const path = [
+          `M ${cx} ${cy}`,
+          `L ${cx + radius * Math.cos(start)} ${cy + radius * Math.sin(start)}`,
+          `A ${radius} ${radius} 0 ${largeArc} 1 ${cx + radius * Math.cos(angle)} ${cy + radius * Math.sin(angle)}`,
+          "Z",
+        ].join(" ");
+        return (
+          <path key={index} d={path} fill={color}>
+            {title}
+          </path>
+        );
+      })}
+    </svg>
+  );
+};
+
+function toNumber(value: unknown): number {
+  const number = typeof value === "number" ? value : Number(value);
+  return Number.isFinite(number) ? number : 0;
+}
+
+function formatTick(value: number): string {
+  return new Intl.NumberFormat(undefined, { notation: "compact", maximumFractionDigits: 1 }).format(value);
+}
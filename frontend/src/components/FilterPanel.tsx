@@ -1,6 +1,7 @@
 import React from "react";
 
-import type {FilterSpec} from "../types";
+import {fetchFilterValues} from "../api";
+import type {FilterSpec, ValueOption} from "../types";
 
 export type FilterState = Record<
     string,
@@ -11,6 +12,9 @@ export type FilterState = Record<
 >;
 
 type Props = {
+    // valuesSource owns the filters and loads their values_from options,
+    // e.g. "widgets/users" or "pages/overview".
+    valuesSource?: string;
     filters: FilterSpec[];
     state: FilterState;
     onChange: (next: FilterState) => void;
@@ -18,7 +22,9 @@ type Props = {
     onReset: () => void;
 };
 
-export const FilterPanel: React.FC<Props> = ({filters, state, onChange, onApply, onReset}) => {
+export const FilterPanel: React.FC<Props> = ({valuesSource, filters, state, onChange, onApply, onReset}) => {
+    const options = useFilterValues(valuesSource, filters);
+
     return (
         <div className="filters">
             <table className="filter-table">
@@ -51,7 +57,7 @@ export const FilterPanel: React.FC<Props> = ({filters, state, onChange, onApply,
                                 ))}
                             </select>
                         )}
-                                    {renderFilterInput(filter, current, operator, state, onChange)}
+                                    {renderFilterInput(filter, options[filter.id] ?? filter.values, current, operator, state, onChange)}
                                 </td>
                             </tr>
                         );
@@ -77,8 +83,36 @@ export const FilterPanel: React.FC<Props> = ({filters, state, onChange, onApply,
     );
 };
 
+// useFilterValues loads the options of filters with values_from, keyed by
+// filter ID. Filters whose values fail to load keep their static values.
+function useFilterValues(source: string | undefined, filters: FilterSpec[]): Record<string, ValueOption[]> {
+    const [options, setOptions] = React.useState<Record<string, ValueOption[]>>({});
+
+    React.useEffect(() => {
+        if (!source) return;
+        let active = true;
+        filters
+            .filter((filter) => filter.values_from)
+            .forEach((filter) => {
+                fetchFilterValues(source, filter.id)
+                    .then((result) => {
+                        if (active) {
+                            setOptions((prev) => ({...prev, [filter.id]: result.values}));
+                        }
+                    })
+                    .catch((err) => console.error(err));
+            });
+        return () => {
+            active = false;
+        };
+    }, [source, filters]);
+
+    return options;
+}
+
 function renderFilterInput(
     filter: FilterSpec,
+    values: ValueOption[] | undefined,
     current: FilterState[string],
     operator: string,
     state: FilterState,
@@ -100,7 +134,7 @@ function renderFilterInput(
                 }
             >
                 <option value="">Select...</option>
-                {filter.values?.map((option) => (
+                {values?.map((option) => (
                     <option key={option.value} value={option.value}>
                         {option.label}
                     </option>
@@ -122,7 +156,7 @@ function renderFilterInput(
                     })
                 }
             >
-                {filter.values?.map((option) => (
+                {values?.map((option) => (
                     <option key={option.value} value={option.value}>
                         {option.label}
                     </option>
@@ -0,0 +1,60 @@
+import React from "react";
+
+import type { StatFormat, StatResponse, Widget } from "../types";
+
+type Props = {
+  widget: Widget;
+  data: StatResponse;
+};
+
+export const StatWidget: React.FC<Props> = ({ widget, data }) => {
+  const format = widget.stat?.format;
+  const delta = data.delta_percent ?? data.delta;
+  const trend = delta === undefined || delta === 0 ? "flat" : delta > 0 ? "up" : "down";
+
+  return (
+    <div className="stat">
+      <div className="stat-value">{formatStatValue(data.value, format)}</div>
+      {data.label && <div className="stat-label">{data.label}</div>}
+      {delta !== undefined && (
+        <div className={`stat-delta stat-delta-${trend}`}>
+          {trend === "up" ? "▲" : trend === "down" ? "▼" : "•"}{" "}
+          {data.delta_percent !== undefined
+            ? `${Math.abs(data.delta_percent).toFixed(1)}%`
+            : formatStatValue(Math.abs(data.delta ?? 0), format)}
+          {data.previous !== undefined && data.previous !== null && (
+            <span className="stat-previous"> vs {formatStatValue(data.previous, format)}</span>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
+
+// formatStatValue applies the widget's number format. Values that are not
+// numbers are shown as they are.
+export function formatStatValue(value: unknown, format?: StatFormat): string {
+  if (value === null || value === undefined) return "—";
+  const number = typeof value === "number" ? value : Number(value);
+  if (typeof value === "boolean" || (typeof value === "string" && value.trim() === "") || Number.isNaN(number)) {
+    return String(value);
+  }
+
+  const options: Intl.NumberFormatOptions = {};
+  if (format?.style === "percent") {
+    options.style = "percent";
+  } else if (format?.style === "currency" && format.currency) {
+    options.style = "currency";
+    options.currency = format.currency;
+  }
+  if (format?.decimals !== undefined) {
+    options.minimumFractionDigits = format.decimals;
+    options.maximumFractionDigits = format.decimals;
+  }
+  if (format?.compact) {
+    options.notation = "compact";
+  }
+
+  const formatted = new Intl.NumberFormat(undefined, options).format(number);
+  return `${format?.prefix ?? ""}${formatted}${format?.suffix ?? ""}`;
+}
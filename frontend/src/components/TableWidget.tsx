@@ -1,7 +1,8 @@
 import React from "react";
 import { Link } from "react-router-dom";
 
-import type { ColumnSpec, DataResponse, FilterSpec, Widget } from "../types";
+import { runRowAction } from "../api";
+import type { ColumnRender, ColumnSpec, DataResponse, FilterSpec, RowAction, Widget } from "../types";
 import { FilterPanel, type FilterState } from "./FilterPanel";
 
 type Props = {
@@ -14,6 +15,10 @@ type Props = {
   onFilterChange: (next: FilterState) => void;
   onApplyFilters: () => void;
   onResetFilters: () => void;
+  query: URLSearchParams;
+  onActionDone: () => void;
+  search: string;
+  onSearch: (search: string) => void;
 };
 
 export const TableWidget: React.FC<Props> = ({
@@ -26,12 +31,64 @@ export const TableWidget: React.FC<Props> = ({
   onFilterChange,
   onApplyFilters,
   onResetFilters,
+  query,
+  onActionDone,
+  search,
+  onSearch,
 }) => {
   const columns = widget.table?.columns ?? [];
+  const actions = widget.table?.row_actions ?? [];
+  const [running, setRunning] = React.useState<string | null>(null);
+  const [searchDraft, setSearchDraft] = React.useState(search);
+
+  React.useEffect(() => {
+    setSearchDraft(search);
+  }, [search]);
+
+  const handleAction = async (action: RowAction, row: Record<string, unknown>, key: string) => {
+    const params = askParams(action);
+    if (!params) return;
+    if (action.confirm && !window.confirm(applyTemplate(action.confirm, row))) return;
+    setRunning(key);
+    try {
+      const result = await runRowAction(widget.id, action.id, query, {
+        key: row[widget.table?.row_key ?? "id"],
+        params,
+        confirmed: Boolean(action.confirm),
+      });
+      if (result.message) window.alert(result.message);
+      onActionDone();
+    } catch (err) {
+      window.alert(err instanceof Error ? err.message : String(err));
+    } finally {
+      setRunning(null);
+    }
+  };
+
   return (
     <div className="table">
+      {widget.table?.search && (
+        <form
+          className="table-search"
+          role="search"
+          onSubmit={(event) => {
+            event.preventDefault();
+            onSearch(searchDraft.trim());
+          }}
+        >
+          <input
+            className="filter-input"
+            type="search"
+            placeholder={widget.table.search.placeholder ?? "Search..."}
+            value={searchDraft}
+            onChange={(event) => setSearchDraft(event.target.value)}
+          />
+          <button type="submit">Search</button>
+        </form>
+      )}
       {filters.length > 0 && (
         <FilterPanel
+          valuesSource={`widgets/${widget.id}`}
           filters={filters}
           state={filtersDraft}
           onChange={onFilterChange}
@@ -45,12 +102,13 @@ export const TableWidget: React.FC<Props> = ({
             {columns.map((col) => (
               <th key={col.id}>{col.title ?? col.id}</th>
             ))}
+            {actions.length > 0 && <th aria-label="Actions" />}
           </tr>
         </thead>
         <tbody>
           {data.data.length === 0 ? (
             <tr>
-              <td colSpan={Math.max(columns.length, 1)}>No rows</td>
+              <td colSpan={Math.max(columns.length + (actions.length > 0 ? 1 : 0), 1)}>No rows</td>
             </tr>
           ) : (
             data.data.map((row, idx) => (
@@ -58,6 +116,20 @@ export const TableWidget: React.FC<Props> = ({
                 {columns.map((col) => (
                   <td key={col.id}>{renderCell(col, row)}</td>
                 ))}
+                {actions.length > 0 && (
+                  <td className="row-actions">
+                    {actions.map((action) => (
+                      <button
+                        key={action.id}
+                        type="button"
+                        disabled={running !== null}
+                        onClick={() => handleAction(action, row, `${idx}:${action.id}`)}
+                      >
+                        {running === `${idx}:${action.id}` ? "Running..." : action.label}
+                      </button>
+                    ))}
+                  </td>
+                )}
               </tr>
             ))
           )}
@@ -74,13 +146,42 @@ export const TableWidget: React.FC<Props> = ({
   );
 };
 
+// askParams prompts for the action's parameters; it returns null when the
+// operator cancels or leaves a required value empty.
+function askParams(action: RowAction): Record<string, string> | null {
+  const params: Record<string, string> = {};
+  for (const param of action.params ?? []) {
+    const choices = param.values?.map((v) => `${v.value} (${v.label})`).join(", ");
+    const value = window.prompt(choices ? `${param.title}: ${choices}` : param.title, "");
+    if (value === null || (param.required && value.trim() === "")) return null;
+    params[param.id] = value.trim();
+  }
+  return params;
+}
+
 function renderCell(column: ColumnSpec, row: Record<string, unknown>) {
   const rawValue = row[column.id];
-  const fallbackText = rawValue === null || rawValue === undefined ? "" : String(rawValue);
   const render = column.render;
-  if (!render || render.type !== "link") {
-    return formatValue(rawValue);
+  switch (render?.type) {
+    case "link":
+      return renderLink(render, rawValue, row);
+    case "badge":
+      return renderBadge(render, rawValue);
+    case "boolean":
+      return renderBoolean(rawValue);
+    case "image":
+      return renderImage(render, row);
+    case "json":
+      return renderJSON(render, rawValue);
+    case "datetime":
+      return renderDateTime(render, rawValue);
+    default:
+      return formatValue(rawValue);
   }
+}
+
+function renderLink(render: ColumnRender, rawValue: unknown, row: Record<string, unknown>) {
+  const fallbackText = rawValue === null || rawValue === undefined ? "" : String(rawValue);
   const url = render.url ? applyTemplate(render.url, row) : "";
   const text = render.text ? applyTemplate(render.text, row) : fallbackText;
   if (!url) {
@@ -96,6 +197,122 @@ function renderCell(column: ColumnSpec, row: Record<string, unknown>) {
   return <Link to={url}>{text}</Link>;
 }
 
+function renderBadge(render: ColumnRender, value: unknown) {
+  if (value === null || value === undefined || value === "") return "";
+  const text = String(value);
+  const color = render.colors?.[text] ?? render.default ?? "gray";
+  if (color.startsWith("#")) {
+    return (
+      <span className="badge" style={{ color, borderColor: color, background: `${color}1a` }}>
+        {text}
+      </span>
+    );
+  }
+  return <span className={`badge badge-${color}`}>{text}</span>;
+}
+
+const trueValues = new Set(["true", "t", "1", "yes", "y"]);
+const falseValues = new Set(["false", "f", "0", "no", "n"]);
+
+function renderBoolean(value: unknown) {
+  const text = String(value ?? "").toLowerCase();
+  if (value === true || trueValues.has(text)) {
+    return (
+      <span className="bool bool-true" aria-label="yes">
+        ✓
+      </span>
+    );
+  }
+  if (value === false || falseValues.has(text)) {
+    return (
+      <span className="bool bool-false" aria-label="no">
+        ✗
+      </span>
+    );
+  }
+  return "";
+}
+
+function renderImage(render: ColumnRender, row: Record<string, unknown>) {
+  const src = render.url ? applyTemplate(render.url, row) : "";
+  if (!src) return "";
+  return (
+    <img
+      className="cell-image"
+      src={src}
+      alt={render.text ? applyTemplate(render.text, row) : ""}
+      loading="lazy"
+      style={{ maxWidth: render.width || 48, maxHeight: render.height || 48 }}
+    />
+  );
+}
+
+function renderJSON(render: ColumnRender, value: unknown) {
+  if (value === null || value === undefined) return "";
+  const summary = Array.isArray(value)
+    ? `[${value.length}]`
+    : typeof value === "object"
+      ? `{${Object.keys(value as object).length}}`
+      : String(value);
+  return (
+    <details className="cell-json" open={!render.collapsed}>
+      <summary>{summary}</summary>
+      <pre>{JSON.stringify(value, null, 2)}</pre>
+    </details>
+  );
+}
+
+function renderDateTime(render: ColumnRender, value: unknown) {
+  const date = parseDate(value);
+  if (!date) return formatValue(value);
+  const absolute = date.toLocaleString();
+  if (!render.relative) {
+    return <time dateTime={date.toISOString()}>{absolute}</time>;
+  }
+  return (
+    <time dateTime={date.toISOString()} title={absolute}>
+      {relativeTime(date)}
+    </time>
+  );
+}
+
+// parseDate reads ISO strings and Unix timestamps; strings without a zone
+// are UTC, as on the server.
+function parseDate(value: unknown): Date | null {
+  let date: Date;
+  if (typeof value === "number") {
+    date = new Date(value < 1e12 ? value * 1000 : value);
+  } else if (typeof value === "string" && value !== "") {
+    const iso = /^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}(:\d{2}(\.\d+)?)?$/.test(value)
+      ? `${value.replace(" ", "T")}Z`
+      : value;
+    date = new Date(iso);
+  } else {
+    return null;
+  }
+  return Number.isNaN(date.getTime()) ? null : date;
+}
+
+const relativeUnits: [Intl.RelativeTimeFormatUnit, number][] = [
+  ["year", 365 * 24 * 3600],
+  ["month", 30 * 24 * 3600],
+  ["week", 7 * 24 * 3600],
+  ["day", 24 * 3600],
+  ["hour", 3600],
+  ["minute", 60],
+];
+
+function relativeTime(date: Date): string {
+  const seconds = (date.getTime() - Date.now()) / 1000;
+  const format = new Intl.RelativeTimeFormat(undefined, { numeric: "auto" });
+  for (const [unit, size] of relativeUnits) {
+    if (Math.abs(seconds) >= size) {
+      return format.format(Math.round(seconds / size), unit);
+    }
+  }
+  return format.format(Math.round(seconds), "second");
+}
+
 function applyTemplate(template: string, row: Record<string, unknown>) {
   return template.replace(/{{\s*([^}]+)\s*}}/g, (_, key) => {
     const value = row[String(key).trim()];
@@ -3,8 +3,10 @@ import { useNavigate } from "react-router-dom";
 import type { Location } from "react-router-dom";
 
 import { fetchWidgetData } from "../api";
-import type { DataResponse, Widget } from "../types";
-import { appendFilters, emptyFilterState, parseFilterParams, type FilterState } from "./FilterPanel";
+import type { ChartResponse, FilterSpec, StatResponse, Widget, WidgetResponse } from "../types";
+import { ChartWidget } from "./ChartWidget";
+import { appendFilters, emptyFilterState, FilterPanel, parseFilterParams, type FilterState } from "./FilterPanel";
+import { StatWidget } from "./StatWidget";
 import { TableWidget } from "./TableWidget";
 
 type Props = {
@@ -14,19 +16,22 @@ type Props = {
 
 export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
   const navigate = useNavigate();
-  const [data, setData] = useState<DataResponse | null>(null);
+  const [data, setData] = useState<WidgetResponse | null>(null);
   const [rows, setRows] = useState<Record<string, unknown>[]>([]);
   const [nextCursor, setNextCursor] = useState<string | undefined>();
   const [hasMore, setHasMore] = useState(false);
   const [loading, setLoading] = useState(true);
   const [loadingMore, setLoadingMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
+  const [reloadKey, setReloadKey] = useState(0);
+
+  const filterSpecs = useMemo(() => widgetFilters(widget), [widget]);
 
   const baseParams = useMemo(() => {
     const params = new URLSearchParams(location.search);
     params.delete("offset");
     params.delete("limit");
-    const filterIds = widget.table?.filters?.map((filter) => filter.id) ?? [];
+    const filterIds = filterSpecs.map((filter) => filter.id);
     if (filterIds.length > 0) {
       const keys = Array.from(params.keys());
       keys.forEach((key) => {
@@ -39,11 +44,11 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
       });
     }
     return params;
-  }, [location.search, widget.table?.filters]);
+  }, [location.search, filterSpecs]);
 
   const filtersFromUrl = useMemo(
-    () => parseFilterParams(widget.table?.filters ?? [], location.search),
-    [widget.id, widget.table?.filters, location.search],
+    () => parseFilterParams(filterSpecs, location.search),
+    [filterSpecs, location.search],
   );
   const [filtersDraft, setFiltersDraft] = useState<FilterState>(filtersFromUrl);
 
@@ -53,9 +58,9 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
 
   const params = useMemo(() => {
     const combined = new URLSearchParams(baseParams);
-    appendFilters(combined, filtersFromUrl, widget.table?.filters ?? []);
+    appendFilters(combined, filtersFromUrl, filterSpecs);
     return combined;
-  }, [baseParams, filtersFromUrl, widget.table?.filters]);
+  }, [baseParams, filtersFromUrl, filterSpecs]);
 
   useEffect(() => {
     let active = true;
@@ -63,11 +68,11 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
     setRows([]);
     setNextCursor(undefined);
     setHasMore(false);
-    fetchWidgetData(widget.id, params)
+    fetchWidgetData<WidgetResponse>(widget.id, params)
       .then((payload) => {
         if (!active) return;
         setData(payload);
-        setRows(payload.data);
+        setRows("data" in payload ? payload.data : []);
         setNextCursor(payload.next_cursor);
         setHasMore(Boolean(payload.has_more));
         setError(null);
@@ -83,7 +88,7 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
     return () => {
       active = false;
     };
-  }, [widget.id, params]);
+  }, [widget.id, params, reloadKey]);
 
   const handleLoadMore = async () => {
     if (!nextCursor || loadingMore) return;
@@ -106,7 +111,7 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
 
   const applyFiltersToUrl = (nextFilters: FilterState) => {
     const nextParams = new URLSearchParams(baseParams);
-    appendFilters(nextParams, nextFilters, widget.table?.filters ?? []);
+    appendFilters(nextParams, nextFilters, filterSpecs);
     navigate(
       {
         search: nextParams.toString() ? `?${nextParams.toString()}` : "",
@@ -115,12 +120,36 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
     );
   };
 
+  const search = useMemo(() => new URLSearchParams(location.search).get("q") ?? "", [location.search]);
+
+  const handleSearch = (nextSearch: string) => {
+    const nextParams = new URLSearchParams(location.search);
+    nextParams.delete("offset");
+    if (nextSearch) {
+      nextParams.set("q", nextSearch);
+    } else {
+      nextParams.delete("q");
+    }
+    navigate({ search: nextParams.toString() ? `?${nextParams.toString()}` : "" }, { replace: false });
+  };
+
   const handleResetFilters = () => {
-    const cleared = emptyFilterState(widget.table?.filters ?? []);
+    const cleared = emptyFilterState(filterSpecs);
     setFiltersDraft(cleared);
     applyFiltersToUrl(cleared);
   };
 
+  const filterPanel = filterSpecs.length > 0 && (
+    <FilterPanel
+      valuesSource={`widgets/${widget.id}`}
+      filters={filterSpecs}
+      state={filtersDraft}
+      onChange={setFiltersDraft}
+      onApply={() => applyFiltersToUrl(filtersDraft)}
+      onReset={handleResetFilters}
+    />
+  );
+
   return (
     <section className="widget">
       <header className="widget-header">
@@ -143,17 +172,37 @@ export const WidgetCard: React.FC<Props> = ({ widget, location }) => {
           }}
           onLoadMore={handleLoadMore}
           loadingMore={loadingMore}
-          filters={widget.table?.filters ?? []}
+          filters={filterSpecs}
           filtersDraft={filtersDraft}
           onFilterChange={setFiltersDraft}
           onApplyFilters={() => {
             applyFiltersToUrl(filtersDraft);
           }}
           onResetFilters={handleResetFilters}
+          query={params}
+          onActionDone={() => setReloadKey((key) => key + 1)}
+          search={search}
+          onSearch={handleSearch}
         />
+      ) : widget.type === "stat" ? (
+        <>
+          {filterPanel}
+          <StatWidget widget={widget} data={data as StatResponse} />
+        </>
+      ) : widget.type === "chart" ? (
+        <>
+          {filterPanel}
+          <ChartWidget widget={widget} data={data as ChartResponse} />
+        </>
       ) : (
         <div className="state">Unsupported widget type: {widget.type}</div>
       )}
     </section>
   );
 };
+
+// widgetFilters returns the filters declared by the widget's table, stat or
+// chart spec.
+function widgetFilters(widget: Widget): FilterSpec[] {
+  return widget.table?.filters ?? widget.stat?.filters ?? widget.chart?.filters ?? [];
+}
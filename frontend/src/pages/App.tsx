@@ -1,9 +1,9 @@
 import React, { useEffect, useMemo, useState } from "react";
 import { Link, NavLink, Route, Routes, useLocation } from "react-router-dom";
 
-import { fetchConfig } from "../api";
-import { setPathPrefix } from "../pathPrefix";
-import type { AppConfig, MenuItem, Page } from "../types";
+import { fetchConfig, fetchMe } from "../api";
+import { setPathPrefix, withPathPrefix } from "../pathPrefix";
+import type { AppConfig, Identity, MenuItem, Page } from "../types";
 import { PageView } from "./PageView";
 
 const defaultConfig: AppConfig = {
@@ -16,6 +16,7 @@ export const App: React.FC = () => {
   const [config, setConfig] = useState<AppConfig>(defaultConfig);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
+  const [identity, setIdentity] = useState<Identity | null>(null);
   const location = useLocation();
 
   useEffect(() => {
@@ -27,6 +28,13 @@ export const App: React.FC = () => {
         setConfig(cfg);
         setPathPrefix(cfg.path_prefix);
         setError(null);
+        // The identity is only known once the path prefix is set; without
+        // auth there is none and the user block stays hidden.
+        fetchMe()
+          .then((me) => {
+            if (active) setIdentity(me);
+          })
+          .catch((err) => console.error(err));
       })
       .catch((err) => {
         if (!active) return;
@@ -47,6 +55,14 @@ export const App: React.FC = () => {
     <div className="app">
       <aside className="sidebar">
         <div className="brand">{config.title}</div>
+        {identity && (
+          <div className="user">
+            <span className="user-name">{identity.subject}</span>
+            <form method="post" action={withPathPrefix("/auth/logout")}>
+              <button type="submit">Log out</button>
+            </form>
+          </div>
+        )}
         {renderMenu(config.menu)}
       </aside>
       <main className="content">
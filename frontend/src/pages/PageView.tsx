@@ -1,7 +1,15 @@
-import React from "react";
+import React, { useEffect, useMemo, useState } from "react";
+import { useNavigate } from "react-router-dom";
 import type { Location } from "react-router-dom";
 
-import type { Page } from "../types";
+import type { FilterSpec, Page } from "../types";
+import {
+  appendFilters,
+  emptyFilterState,
+  FilterPanel,
+  parseFilterParams,
+  type FilterState,
+} from "../components/FilterPanel";
 import { WidgetCard } from "../components/WidgetCard";
 
 export const PageView: React.FC<{ page: Page; location: Location }> = ({ page, location }) => {
@@ -10,6 +18,9 @@ export const PageView: React.FC<{ page: Page; location: Location }> = ({ page, l
       <div className="page-header">
         <h1>{page.title}</h1>
       </div>
+      {page.filters && page.filters.length > 0 && (
+        <PageFilterBar slug={page.slug} filters={page.filters} location={location} />
+      )}
       <div className="widgets">
         {page.widgets.map((widget) => (
           <WidgetCard key={widget.id} widget={widget} location={location} />
@@ -18,3 +29,48 @@ export const PageView: React.FC<{ page: Page; location: Location }> = ({ page, l
     </div>
   );
 };
+
+// PageFilterBar edits the page filters in the URL. Widgets send every URL
+// param they do not own with their requests, so the values reach each widget.
+const PageFilterBar: React.FC<{ slug: string; filters: FilterSpec[]; location: Location }> = ({
+  slug,
+  filters,
+  location,
+}) => {
+  const navigate = useNavigate();
+  const filtersFromUrl = useMemo(() => parseFilterParams(filters, location.search), [filters, location.search]);
+  const [draft, setDraft] = useState<FilterState>(filtersFromUrl);
+
+  useEffect(() => {
+    setDraft(filtersFromUrl);
+  }, [filtersFromUrl]);
+
+  const apply = (next: FilterState) => {
+    const params = new URLSearchParams(location.search);
+    params.delete("offset");
+    Array.from(params.keys()).forEach((key) => {
+      if (filters.some((filter) => key === filter.id || key.startsWith(`${filter.id}.`))) {
+        params.delete(key);
+      }
+    });
+    appendFilters(params, next, filters);
+    navigate({ search: params.toString() ? `?${params.toString()}` : "" }, { replace: false });
+  };
+
+  return (
+    <div className="page-filters">
+      <FilterPanel
+        valuesSource={`pages/${slug}`}
+        filters={filters}
+        state={draft}
+        onChange={setDraft}
+        onApply={() => apply(draft)}
+        onReset={() => {
+          const cleared = emptyFilterState(filters);
+          setDraft(cleared);
+          apply(cleared);
+        }}
+      />
+    </div>
+  );
+};
@@ -15,6 +15,7 @@ export type MenuItem = {
 export type Page = {
   slug: string;
   title: string;
+  filters?: FilterSpec[];
   widgets: Widget[];
 };
 
@@ -23,24 +24,100 @@ export type Widget = {
   title: string;
   type: string;
   table?: TableSpec;
+  stat?: StatSpec;
+  chart?: ChartSpec;
+  page_filters?: Record<string, string>;
+};
+
+export type ChartSpec = {
+  kind: "line" | "bar" | "pie";
+  dimension: string;
+  bucket?: "hour" | "day" | "week" | "month";
+  measures: MeasureSpec[];
+  limit?: number;
+  filters?: FilterSpec[];
+};
+
+export type MeasureSpec = {
+  id?: string;
+  title?: string;
+  column?: string;
+  aggregate: string;
+};
+
+export type StatSpec = {
+  value?: string;
+  label?: string;
+  delta?: string;
+  previous?: string;
+  aggregate?: string;
+  format?: StatFormat;
+  filters?: FilterSpec[];
+};
+
+export type StatFormat = {
+  style?: "number" | "percent" | "currency";
+  currency?: string;
+  decimals?: number;
+  prefix?: string;
+  suffix?: string;
+  compact?: boolean;
 };
 
 export type TableSpec = {
   columns: ColumnSpec[];
   filters?: FilterSpec[];
+  search?: SearchSpec;
+  row_actions?: RowAction[];
+  row_key?: string;
+};
+
+export type RowAction = {
+  id: string;
+  label: string;
+  confirm?: string;
+  roles?: string[];
+  params?: ActionParam[];
+};
+
+export type ActionParam = {
+  id: string;
+  title: string;
+  type?: "text" | "number" | "select_one";
+  required?: boolean;
+  values?: ValueOption[];
+};
+
+export type ActionResponse = {
+  ok: boolean;
+  rows_affected: number;
+  message?: string;
+};
+
+export type SearchSpec = {
+  columns: string[];
+  placeholder?: string;
 };
 
 export type ColumnSpec = {
   id: string;
   title?: string;
   render?: ColumnRender;
+  mask?: { type: string };
+  format?: { type: string };
 };
 
 export type ColumnRender = {
-  type: string;
+  type: "link" | "badge" | "boolean" | "image" | "json" | "datetime";
   text?: string;
   url?: string;
   external?: boolean;
+  colors?: Record<string, string>;
+  default?: string;
+  width?: number;
+  height?: number;
+  collapsed?: boolean;
+  relative?: boolean;
 };
 
 export type FilterSpec = {
@@ -50,6 +127,16 @@ export type FilterSpec = {
   target: string;
   operators?: string[];
   values?: ValueOption[];
+  values_from?: ValuesSourceSpec;
+};
+
+export type ValuesSourceSpec = {
+  limit?: number;
+};
+
+export type FilterValuesResponse = {
+  values: ValueOption[];
+  has_more?: boolean;
 };
 
 export type ValueOption = {
@@ -63,3 +150,32 @@ export type DataResponse = {
   next_cursor?: string;
   has_more?: boolean;
 };
+
+export type StatResponse = {
+  value: unknown;
+  label?: string;
+  previous?: unknown;
+  delta?: number;
+  delta_percent?: number;
+};
+
+export type ChartResponse = {
+  labels: unknown[];
+  series: { id: string; title: string; data: unknown[] }[];
+};
+
+export type WidgetResponse = DataResponse | StatResponse | ChartResponse;
+
+export type ApiError = {
+  code: string;
+  message: string;
+  field?: string;
+  retry_after?: number;
+};
+
+export type Identity = {
+  subject: string;
+  roles?: string[];
+  attributes?: Record<string, string>;
+  widgets?: string[];
+};
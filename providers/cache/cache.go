@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const DefaultCapacity = 1024
+
+// Backend stores cached responses. Entries belong to a widget so that all
+// responses of a widget can be purged at once.
+type Backend interface {
+	Get(key string) (providers.DataResponse, bool)
+	Set(widgetID, key string, resp providers.DataResponse, ttl time.Duration)
+	Purge(widgetID string)
+}
+
+// Provider decorates another provider with response caching for widgets
+// that declare a cache TTL. Concurrent misses for the same key are coalesced
+// into a single fetch.
+type Provider struct {
+	next    providers.Provider
+	backend Backend
+	flights flightGroup
+}
+
+type Option func(*Provider)
+
+func WithBackend(backend Backend) Option {
+	return func(p *Provider) {
+		p.backend = backend
+	}
+}
+
+func New(next providers.Provider, opts ...Option) *Provider {
+	p := &Provider{next: next}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.backend == nil {
+		p.backend = NewLRU(DefaultCapacity)
+	}
+	return p
+}
+
+func (p *Provider) Init(ctx context.Context, name string, providerConfig config.ProviderConfig) error {
+	return p.next.Init(ctx, name, providerConfig)
+}
+
+func (p *Provider) Fetch(ctx context.Context, widget config.Widget, req providers.DataRequest) (providers.DataResponse, error) {
+	if widget.Cache == nil || widget.Cache.TTL <= 0 || req.RowKey != nil {
+		return p.next.Fetch(ctx, widget, req)
+	}
+
+	key := Key(widget.ID, maskScope(widget, req))
+	if resp, ok := p.backend.Get(key); ok {
+		return resp, nil
+	}
+
+	return p.flights.do(ctx, key, func(ctx context.Context) (providers.DataResponse, error) {
+		if resp, ok := p.backend.Get(key); ok {
+			return resp, nil
+		}
+		resp, err := p.next.Fetch(ctx, widget, req)
+		if err != nil {
+			return providers.DataResponse{}, err
+		}
+		p.backend.Set(widget.ID, key, resp, widget.Cache.TTL)
+		return resp, nil
+	})
+}
+
+func (p *Provider) Purge(widgetID string) {
+	p.backend.Purge(widgetID)
+}
+
+// Ping forwards to the decorated provider when it supports health checks.
+func (p *Provider) Ping(ctx context.Context) error {
+	if checker, ok := p.next.(providers.HealthChecker); ok {
+		return checker.Ping(ctx)
+	}
+	return nil
+}
+
+// RunAction forwards to the decorated provider when it runs row actions.
+func (p *Provider) RunAction(ctx context.Context, widget config.Widget, statement string, args map[string]any) (int64, error) {
+	runner, ok := p.next.(providers.ActionRunner)
+	if !ok {
+		return 0, providers.NewError(providers.ConfigError, "provider does not support actions")
+	}
+	return runner.RunAction(ctx, widget, statement, args)
+}
+
+// Close forwards to the decorated provider when it holds resources.
+func (p *Provider) Close() error {
+	if closer, ok := p.next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// maskScope adds the columns masked for the caller to the scope of req, so
+// that raw values are never served to callers who should see them masked.
+func maskScope(widget config.Widget, req providers.DataRequest) providers.DataRequest {
+	if masked := providers.MaskedColumns(widget, req.Roles); len(masked) > 0 {
+		req.Scope += "|masked:" + strings.Join(masked, ",")
+	}
+	return req
+}
+
+type keyFilter struct {
+	Name     string                `json:"n"`
+	Operator config.FilterOperator `json:"o,omitempty"`
+	Values   []string              `json:"v"`
+}
+
+type keyData struct {
+	Widget  string      `json:"w"`
+	Scope   string      `json:"s,omitempty"`
+	Limit   int         `json:"l"`
+	Cursor  string      `json:"c,omitempty"`
+	Search  string      `json:"q,omitempty"`
+	Filters []keyFilter `json:"f,omitempty"`
+}
+
+// Key builds the cache key of a widget request. Filters are normalized so
+// that the order of query params does not produce distinct entries.
+func Key(widgetID string, req providers.DataRequest) string {
+	data := keyData{
+		Widget: widgetID,
+		Scope:  req.Scope,
+		Limit:  req.Limit,
+		Cursor: req.Cursor,
+		Search: req.Search,
+	}
+	for _, filter := range req.Filters {
+		if len(filter.Values) == 0 {
+			continue
+		}
+		data.Filters = append(data.Filters, keyFilter{
+			Name:     filter.Name,
+			Operator: filter.Operator,
+			Values:   filter.Values,
+		})
+	}
+	sort.SliceStable(data.Filters, func(i, j int) bool {
+		if data.Filters[i].Name != data.Filters[j].Name {
+			return data.Filters[i].Name < data.Filters[j].Name
+		}
+		return data.Filters[i].Operator < data.Filters[j].Operator
+	})
+
+	encoded, _ := json.Marshal(data)
+	sum := sha256.Sum256(encoded)
+	return widgetID + ":" + hex.EncodeToString(sum[:])
+}
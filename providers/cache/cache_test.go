@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+type countingProvider struct {
+	calls  atomic.Int32
+	delay  time.Duration
+	panics bool
+}
+
+func (p *countingProvider) Init(context.Context, string, config.ProviderConfig) error {
+	return nil
+}
+
+func (p *countingProvider) Fetch(ctx context.Context, widget config.Widget, _ providers.DataRequest) (providers.DataResponse, error) {
+	p.calls.Add(1)
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return providers.DataResponse{}, ctx.Err()
+	}
+	if p.panics {
+		panic("boom")
+	}
+	return providers.DataResponse{Data: []map[string]any{{"widget": widget.ID}}, Total: 1}, nil
+}
+
+func cachedWidget(id string) config.Widget {
+	return config.Widget{ID: id, Cache: &config.CacheSpec{TTL: time.Minute}}
+}
+
+func TestProviderCachesAndPurges(t *testing.T) {
+	next := &countingProvider{}
+	provider := New(next)
+	ctx := context.Background()
+	widget := cachedWidget("users")
+
+	req := providers.DataRequest{
+		Limit: 10,
+		Filters: []providers.Filter{
+			{Name: "name", Operator: "contains", Values: []string{"ann"}},
+			{Name: "age", Operator: "gt", Values: []string{"18"}},
+		},
+	}
+	_, err := provider.Fetch(ctx, widget, req)
+	require.NoError(t, err)
+
+	reordered := req
+	reordered.Filters = []providers.Filter{req.Filters[1], req.Filters[0]}
+	_, err = provider.Fetch(ctx, widget, reordered)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, next.calls.Load())
+
+	scoped := req
+	scoped.Scope = "user:bob"
+	_, err = provider.Fetch(ctx, widget, scoped)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, next.calls.Load())
+
+	provider.Purge("users")
+	_, err = provider.Fetch(ctx, widget, req)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, next.calls.Load())
+
+	_, err = provider.Fetch(ctx, config.Widget{ID: "uncached"}, req)
+	require.NoError(t, err)
+	_, err = provider.Fetch(ctx, config.Widget{ID: "uncached"}, req)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, next.calls.Load())
+}
+
+func TestProviderSeparatesMaskScopes(t *testing.T) {
+	next := &countingProvider{}
+	provider := New(next)
+	ctx := context.Background()
+	widget := cachedWidget("users")
+	widget.Table = &config.TableSpec{Columns: []config.ColumnSpec{
+		{ID: "email", Mask: &config.MaskSpec{Type: config.FullMask, ExemptRoles: []string{"support"}}},
+	}}
+
+	for _, roles := range [][]string{nil, {"viewer"}, {"support"}, {"support", "viewer"}} {
+		_, err := provider.Fetch(ctx, widget, providers.DataRequest{Limit: 10, Roles: roles})
+		require.NoError(t, err)
+	}
+	// Callers with the same masked columns share entries.
+	require.EqualValues(t, 2, next.calls.Load())
+}
+
+func TestProviderCoalescesConcurrentMisses(t *testing.T) {
+	next := &countingProvider{delay: 50 * time.Millisecond}
+	provider := New(next)
+	widget := cachedWidget("slow")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 5})
+			if err != nil || resp.Total != 1 {
+				t.Errorf("unexpected response %+v, %v", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.EqualValues(t, 1, next.calls.Load())
+}
+
+func TestProviderSharedFetchSurvivesCanceledCaller(t *testing.T) {
+	next := &countingProvider{delay: 50 * time.Millisecond}
+	provider := New(next)
+	widget := cachedWidget("slow")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := provider.Fetch(ctx, widget, providers.DataRequest{Limit: 5})
+		first <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 5})
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	require.Equal(t, providers.CanceledError, providers.AsError(<-first).Code)
+	require.NoError(t, <-second)
+	require.EqualValues(t, 1, next.calls.Load())
+}
+
+func TestProviderSharedFetchPanics(t *testing.T) {
+	provider := New(&countingProvider{delay: 10 * time.Millisecond, panics: true})
+	widget := cachedWidget("broken")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 5})
+			if providers.AsError(err).Code != providers.InternalError {
+				t.Errorf("expected internal error, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 5})
+	require.Equal(t, providers.InternalError, providers.AsError(err).Code)
+}
+
+func TestLRUEvictsAndExpires(t *testing.T) {
+	lru := NewLRU(2)
+	resp := providers.DataResponse{Total: 1}
+
+	lru.Set("a", "a:1", resp, time.Minute)
+	lru.Set("b", "b:1", resp, time.Minute)
+	_, ok := lru.Get("a:1")
+	require.True(t, ok)
+
+	lru.Set("c", "c:1", resp, time.Minute)
+	_, ok = lru.Get("b:1")
+	require.False(t, ok, "least recently used entry should be evicted")
+	require.Equal(t, 2, lru.Len())
+
+	lru.Set("d", "d:1", resp, -time.Second)
+	_, ok = lru.Get("d:1")
+	require.False(t, ok, "expired entry should not be returned")
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+type flightCall struct {
+	done chan struct{}
+	resp providers.DataResponse
+	err  error
+}
+
+// flightGroup runs at most one fetch per key at a time; callers arriving
+// while a fetch is in flight wait for and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do runs fn once for concurrent callers of key. fn gets a context detached
+// from the callers' cancellation, so that one caller giving up does not fail
+// the others; each caller still stops waiting when its own ctx ends. A panic
+// in fn is returned to every caller as an internal error.
+func (g *flightGroup) do(ctx context.Context, key string,
+	fn func(ctx context.Context) (providers.DataResponse, error)) (providers.DataResponse, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &flightCall{done: make(chan struct{})}
+		g.calls[key] = call
+		go g.run(context.WithoutCancel(ctx), key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		return providers.DataResponse{}, providers.AsError(ctx.Err())
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, call *flightCall,
+	fn func(ctx context.Context) (providers.DataResponse, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.resp, call.err = providers.DataResponse{}, providers.AsError(fmt.Errorf("cached fetch panicked: %v", r))
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	call.resp, call.err = fn(ctx)
+}
@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+const (
+	InvalidRequestError ErrorCode = "invalid_request"
+	InvalidFilterError  ErrorCode = "invalid_filter"
+	UnauthorizedError   ErrorCode = "unauthorized"
+	ForbiddenError      ErrorCode = "forbidden"
+	NotFoundError       ErrorCode = "not_found"
+	MethodError         ErrorCode = "method_not_allowed"
+	RateLimitedError    ErrorCode = "rate_limited"
+	TimeoutError        ErrorCode = "timeout"
+	CanceledError       ErrorCode = "canceled"
+	DatabaseError       ErrorCode = "database_error"
+	UpstreamError       ErrorCode = "upstream_error"
+	ConfigError         ErrorCode = "config_error"
+	InternalError       ErrorCode = "internal_error"
+)
+
+// TimeoutMessage is shown when a query exceeds its time budget.
+const TimeoutMessage = "query too slow, narrow your filters"
+
+// statusClientClosedRequest is the non-standard status used when the client
+// went away before the response was ready.
+const statusClientClosedRequest = 499
+
+type ErrorCode string
+
+// Error is a provider or server failure with a code, an HTTP status and a
+// message that is safe to show to users. Detail and the wrapped error carry
+// internal information such as SQL errors and must not be sent to clients.
+type Error struct {
+	Code    ErrorCode
+	Status  int
+	Message string
+	Field   string
+	Detail  string
+	Err     error
+}
+
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Status: code.Status(), Message: message}
+}
+
+func WrapError(code ErrorCode, message string, err error) *Error {
+	e := NewError(code, message)
+	e.Err = err
+	if err != nil {
+		e.Detail = err.Error()
+	}
+	return e
+}
+
+func InvalidFilter(field, message string) *Error {
+	e := NewError(InvalidFilterError, message)
+	e.Field = field
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" && e.Detail != e.Message {
+		return e.Message + ": " + e.Detail
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status matching the error code.
+func (c ErrorCode) Status() int {
+	switch c {
+	case InvalidRequestError, InvalidFilterError:
+		return http.StatusBadRequest
+	case UnauthorizedError:
+		return http.StatusUnauthorized
+	case ForbiddenError:
+		return http.StatusForbidden
+	case NotFoundError:
+		return http.StatusNotFound
+	case MethodError:
+		return http.StatusMethodNotAllowed
+	case RateLimitedError:
+		return http.StatusTooManyRequests
+	case TimeoutError:
+		return http.StatusGatewayTimeout
+	case CanceledError:
+		return statusClientClosedRequest
+	case DatabaseError, UpstreamError:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// AsError converts any error to an *Error. Context deadlines and
+// cancellations map to timeout and canceled errors, everything else that is
+// not already typed becomes an internal error.
+func AsError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return WrapError(TimeoutError, TimeoutMessage, err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return WrapError(CanceledError, "request canceled", err)
+	}
+	return WrapError(InternalError, "internal error", err)
+}
@@ -0,0 +1,278 @@
+package providers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+const defaultDateLayout = "2006-01-02 15:04"
+
+var (
+	templatePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.]+)\s*}}`)
+
+	// dateLayouts are tried in order for string date values; values without
+	// a zone are read as UTC.
+	dateLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+	currencySymbols = map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥"}
+
+	durationUnits = map[string]time.Duration{
+		"": time.Second, "ms": time.Millisecond, "s": time.Second, "m": time.Minute, "h": time.Hour,
+	}
+
+	locations sync.Map
+)
+
+type formatter func(value any, row map[string]any) any
+
+// FormatRows applies the formats of the widget's table columns to rows.
+// Every format sees the values the row had before formatting.
+func FormatRows(widget config.Widget, rows []map[string]any) {
+	if widget.Table == nil {
+		return
+	}
+
+	columns := map[string]formatter{}
+	for _, column := range widget.Table.Columns {
+		if column.Format != nil {
+			columns[column.ID] = newFormatter(*column.Format)
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
+
+	formatted := make(map[string]any, len(columns))
+	for _, row := range rows {
+		for id, format := range columns {
+			formatted[id] = format(row[id], row)
+		}
+		for id, value := range formatted {
+			row[id] = value
+		}
+	}
+}
+
+func newFormatter(spec config.ColumnFormat) formatter {
+	switch spec.Type {
+	case config.DateFormat:
+		layout := spec.Layout
+		if layout == "" {
+			layout = defaultDateLayout
+		}
+		loc := location(spec.TimeZone)
+		return func(value any, _ map[string]any) any {
+			t, ok := toTime(value)
+			if !ok {
+				return value
+			}
+			return t.In(loc).Format(layout)
+		}
+	case config.NumberFormat, config.CurrencyFormat:
+		return func(value any, _ map[string]any) any {
+			number, ok := toFloat(value)
+			if !ok {
+				return value
+			}
+			return formatNumber(spec, number)
+		}
+	case config.EnumFormat:
+		return func(value any, _ map[string]any) any {
+			if value == nil {
+				return nil
+			}
+			if label, ok := spec.Labels[fmt.Sprint(value)]; ok {
+				return label
+			}
+			return value
+		}
+	case config.BytesFormat:
+		return func(value any, _ map[string]any) any {
+			number, ok := toFloat(value)
+			if !ok {
+				return value
+			}
+			return formatBytes(number)
+		}
+	case config.DurationFormat:
+		unit, ok := durationUnits[spec.Unit]
+		if !ok {
+			unit = time.Second
+		}
+		return func(value any, _ map[string]any) any {
+			number, ok := toFloat(value)
+			if !ok {
+				return value
+			}
+			return formatDuration(time.Duration(number * float64(unit)))
+		}
+	case config.TemplateFormat:
+		return func(_ any, row map[string]any) any {
+			return templatePattern.ReplaceAllStringFunc(spec.Template, func(match string) string {
+				value := row[templatePattern.FindStringSubmatch(match)[1]]
+				if value == nil {
+					return ""
+				}
+				return fmt.Sprint(value)
+			})
+		}
+	}
+	return func(value any, _ map[string]any) any { return value }
+}
+
+// location loads and caches a time zone; unknown zones fall back to UTC.
+// Zones are validated when the config is loaded.
+func location(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+func toTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return time.Unix(v, 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatNumber(spec config.ColumnFormat, number float64) string {
+	decimals := -1
+	thousands := spec.Thousands
+	if spec.Type == config.CurrencyFormat {
+		decimals = 2
+		if spec.Currency == "JPY" {
+			decimals = 0
+		}
+		if thousands == "" {
+			thousands = ","
+		}
+	}
+	if spec.Decimals != nil {
+		decimals = *spec.Decimals
+	}
+
+	text := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	whole, fraction, hasFraction := strings.Cut(text, ".")
+	if thousands != "" {
+		whole = groupDigits(whole, thousands)
+	}
+	if hasFraction {
+		whole += "." + fraction
+	}
+
+	if spec.Type == config.CurrencyFormat {
+		if symbol, ok := currencySymbols[spec.Currency]; ok {
+			whole = symbol + whole
+		} else if spec.Currency != "" {
+			whole = spec.Currency + " " + whole
+		}
+	}
+	if number < 0 && strings.Trim(text, "0.") != "" {
+		whole = "-" + whole
+	}
+	return whole
+}
+
+func groupDigits(digits, separator string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
+
+func formatBytes(number float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for math.Abs(number) >= 1024 && i < len(units)-1 {
+		number /= 1024
+		i++
+	}
+	if i == 0 {
+		return strconv.FormatFloat(number, 'f', -1, 64) + " B"
+	}
+	text := strings.TrimSuffix(strconv.FormatFloat(number, 'f', 1, 64), ".0")
+	return text + " " + units[i]
+}
+
+// formatDuration renders d with its two largest units, e.g. "2d 3h" or
+// "1m 30s"; durations under a second are shown in milliseconds.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d < time.Second {
+		return sign + strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+
+	units := []struct {
+		size time.Duration
+		name string
+	}{{24 * time.Hour, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}}
+	var parts []string
+	for _, unit := range units {
+		if n := d / unit.size; n > 0 {
+			parts = append(parts, strconv.FormatInt(int64(n), 10)+unit.name)
+			d -= n * unit.size
+		} else if len(parts) > 0 {
+			break
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	return sign + strings.Join(parts, " ")
+}
@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+func TestFormatRows(t *testing.T) {
+	two := 2
+	widget := config.Widget{Table: &config.TableSpec{Columns: []config.ColumnSpec{
+		{ID: "created_at", Format: &config.ColumnFormat{Type: config.DateFormat, TimeZone: "Europe/Berlin"}},
+		{ID: "updated_at", Format: &config.ColumnFormat{Type: config.DateFormat, Layout: "02 Jan 2006"}},
+		{ID: "visits", Format: &config.ColumnFormat{Type: config.NumberFormat, Thousands: " "}},
+		{ID: "ratio", Format: &config.ColumnFormat{Type: config.NumberFormat, Decimals: &two}},
+		{ID: "amount", Format: &config.ColumnFormat{Type: config.CurrencyFormat, Currency: "USD"}},
+		{ID: "fee", Format: &config.ColumnFormat{Type: config.CurrencyFormat, Currency: "CHF"}},
+		{ID: "status", Format: &config.ColumnFormat{Type: config.EnumFormat, Labels: map[string]string{"1": "Active"}}},
+		{ID: "size", Format: &config.ColumnFormat{Type: config.BytesFormat}},
+		{ID: "uptime", Format: &config.ColumnFormat{Type: config.DurationFormat}},
+		{ID: "latency", Format: &config.ColumnFormat{Type: config.DurationFormat, Unit: "ms"}},
+		{ID: "name", Format: &config.ColumnFormat{Type: config.TemplateFormat, Template: "{{first}} {{ last }} ({{status}})"}},
+		{ID: "broken", Format: &config.ColumnFormat{Type: config.NumberFormat}},
+	}}}
+
+	rows := []map[string]any{{
+		"created_at": time.Date(2024, 1, 15, 23, 30, 0, 0, time.UTC),
+		"updated_at": "2024-03-01 10:00:00",
+		"visits":     int64(1234567),
+		"ratio":      "0.5",
+		"amount":     -1234.5,
+		"fee":        int64(12),
+		"status":     int64(1),
+		"size":       int64(1536),
+		"uptime":     int64(93784),
+		"latency":    350.0,
+		"first":      "Ann",
+		"last":       "Lee",
+		"broken":     "n/a",
+	}}
+	FormatRows(widget, rows)
+
+	expected := map[string]any{
+		"created_at": "2024-01-16 00:30",
+		"updated_at": "01 Mar 2024",
+		"visits":     "1 234 567",
+		"ratio":      "0.50",
+		"amount":     "-$1,234.50",
+		"fee":        "CHF 12.00",
+		"status":     "Active",
+		"size":       "1.5 KiB",
+		"uptime":     "1d 2h",
+		"latency":    "350ms",
+		"name":       "Ann Lee (1)",
+		"broken":     "n/a",
+		"first":      "Ann",
+	}
+	for column, want := range expected {
+		if got := rows[0][column]; got != want {
+			t.Fatalf("%s = %#v, expected %#v", column, got, want)
+		}
+	}
+}
+
+func TestFormatHelpers(t *testing.T) {
+	for input, want := range map[float64]string{0: "0 B", 1023: "1023 B", 1024: "1 KiB", 5 * 1024 * 1024 * 1024: "5 GiB"} {
+		if got := formatBytes(input); got != want {
+			t.Fatalf("formatBytes(%v) = %q, expected %q", input, got, want)
+		}
+	}
+	for input, want := range map[time.Duration]string{
+		90 * time.Second:              "1m 30s",
+		time.Hour + 5*time.Second:     "1h",
+		-2 * time.Minute:              "-2m",
+		1500 * time.Microsecond:       "1ms",
+		49*time.Hour + 30*time.Minute: "2d 1h",
+	} {
+		if got := formatDuration(input); got != want {
+			t.Fatalf("formatDuration(%v) = %q, expected %q", input, got, want)
+		}
+	}
+}
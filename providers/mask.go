@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+const maskText = "***"
+
+// ColumnMasks returns the masks of the widget's table columns that apply to
+// a caller with roles. Columns are shown raw to callers holding one of their
+// exempt roles.
+func ColumnMasks(widget config.Widget, roles []string) map[string]config.MaskSpec {
+	if widget.Table == nil {
+		return nil
+	}
+
+	var masks map[string]config.MaskSpec
+	for _, column := range widget.Table.Columns {
+		if column.Mask == nil || hasAnyRole(roles, column.Mask.ExemptRoles) {
+			continue
+		}
+		if masks == nil {
+			masks = map[string]config.MaskSpec{}
+		}
+		masks[column.ID] = *column.Mask
+	}
+	return masks
+}
+
+// MaskedColumns returns the sorted IDs of the columns masked for a caller
+// with roles. Responses may only be shared between callers with the same
+// masked columns.
+func MaskedColumns(widget config.Widget, roles []string) []string {
+	masks := ColumnMasks(widget, roles)
+	columns := make([]string, 0, len(masks))
+	for column := range masks {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// MaskRow replaces the values of masked columns in row.
+func MaskRow(row map[string]any, masks map[string]config.MaskSpec) {
+	for column, mask := range masks {
+		if value, ok := row[column]; ok {
+			row[column] = MaskValue(value, mask)
+		}
+	}
+}
+
+// MaskValue masks a single value. Null values stay null; unknown mask types
+// hide the whole value.
+func MaskValue(value any, mask config.MaskSpec) any {
+	if value == nil {
+		return nil
+	}
+	text := fmt.Sprint(value)
+
+	switch mask.Type {
+	case config.PartialMask:
+		if at := strings.LastIndex(text, "@"); at > 0 {
+			return maskText + text[at:]
+		}
+		runes := []rune(text)
+		if len(runes) <= 2 {
+			return maskText
+		}
+		return string(runes[0]) + maskText + string(runes[len(runes)-1])
+	case config.Last4Mask:
+		runes := []rune(text)
+		if len(runes) <= 4 {
+			return maskText
+		}
+		return maskText + string(runes[len(runes)-4:])
+	case config.HashMask:
+		mac := hmac.New(sha256.New, []byte(mask.HashKey))
+		mac.Write([]byte(text))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	return maskText
+}
+
+func hasAnyRole(roles, wanted []string) bool {
+	for _, role := range roles {
+		for _, candidate := range wanted {
+			if role == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
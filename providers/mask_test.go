@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+func TestMaskValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		mask     config.MaskType
+		expected any
+	}{
+		{"ann@example.com", config.FullMask, "***"},
+		{"ann@example.com", config.PartialMask, "***@example.com"},
+		{"Annabel", config.PartialMask, "A***l"},
+		{"An", config.PartialMask, "***"},
+		{"4111111111111111", config.Last4Mask, "***1111"},
+		{int64(1234), config.Last4Mask, "***"},
+		{"secret", "unknown", "***"},
+		{nil, config.FullMask, nil},
+	}
+	for _, tt := range tests {
+		if got := MaskValue(tt.value, config.MaskSpec{Type: tt.mask}); got != tt.expected {
+			t.Fatalf("MaskValue(%v, %s) = %v, expected %v", tt.value, tt.mask, got, tt.expected)
+		}
+	}
+
+	hash := config.MaskSpec{Type: config.HashMask, HashKey: "secret"}
+	hashed := MaskValue("ann@example.com", hash).(string)
+	if len(hashed) != 16 || hashed != MaskValue("ann@example.com", hash) || strings.Contains(hashed, "ann") {
+		t.Fatalf("expected a stable 16 character hash, got %q", hashed)
+	}
+	if hashed == MaskValue("ann@example.com", config.MaskSpec{Type: config.HashMask, HashKey: "other"}) {
+		t.Fatalf("expected the hash to depend on the key")
+	}
+}
+
+func TestColumnMasks(t *testing.T) {
+	widget := config.Widget{Table: &config.TableSpec{Columns: []config.ColumnSpec{
+		{ID: "id"},
+		{ID: "email", Mask: &config.MaskSpec{Type: config.PartialMask, ExemptRoles: []string{"support"}}},
+		{ID: "card", Mask: &config.MaskSpec{Type: config.Last4Mask, ExemptRoles: []string{"billing"}}},
+	}}}
+
+	if got := strings.Join(MaskedColumns(widget, nil), ","); got != "card,email" {
+		t.Fatalf("expected card and email to be masked, got %q", got)
+	}
+	if got := strings.Join(MaskedColumns(widget, []string{"viewer", "support"}), ","); got != "card" {
+		t.Fatalf("expected support to see email, got %q", got)
+	}
+
+	row := map[string]any{"id": 1, "email": "ann@example.com", "card": "4111111111111111"}
+	MaskRow(row, ColumnMasks(widget, []string{"billing"}))
+	if row["email"] != "***@example.com" || row["card"] != "4111111111111111" || row["id"] != 1 {
+		t.Fatalf("unexpected masked row: %v", row)
+	}
+}
@@ -9,7 +9,24 @@ import (
 type DataRequest struct {
 	Limit   int
 	Cursor  string
+	Search  string
 	Filters []Filter
+	// PageFilters are page-level filter specs mapped to the widget's targets.
+	PageFilters []config.FilterSpec
+	// Scope identifies what the caller is allowed to see, e.g. a user or
+	// role, so that cached responses are not shared across scopes.
+	Scope string
+	// Roles of the caller; they decide which masked columns are shown raw.
+	Roles []string
+	// RowKey selects the single row a row action runs on. Such rows bypass
+	// caches and are returned unformatted.
+	RowKey *RowKey
+}
+
+// RowKey matches rows whose Column equals Value.
+type RowKey struct {
+	Column string
+	Value  string
 }
 
 type Filter struct {
@@ -25,6 +42,25 @@ type DataResponse struct {
 	HasMore    bool             `json:"has_more,omitempty"`
 }
 
+type StatResponse struct {
+	Value        any      `json:"value"`
+	Label        string   `json:"label,omitempty"`
+	Previous     any      `json:"previous,omitempty"`
+	Delta        *float64 `json:"delta,omitempty"`
+	DeltaPercent *float64 `json:"delta_percent,omitempty"`
+}
+
+type ChartResponse struct {
+	Labels []any         `json:"labels"`
+	Series []ChartSeries `json:"series"`
+}
+
+type ChartSeries struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Data  []any  `json:"data"`
+}
+
 type Loader[T any] interface {
 }
 
@@ -33,6 +69,24 @@ type Provider interface {
 	Fetch(ctx context.Context, widget config.Widget, req DataRequest) (DataResponse, error)
 }
 
+// HealthChecker is implemented by providers that can verify their backend
+// is reachable.
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+// Purger is implemented by providers that cache responses and can drop
+// everything cached for a widget.
+type Purger interface {
+	Purge(widgetID string)
+}
+
+// ActionRunner is implemented by providers that can run the statements of
+// row actions. Named parameters in statement are bound from args.
+type ActionRunner interface {
+	RunAction(ctx context.Context, widget config.Widget, statement string, args map[string]any) (int64, error)
+}
+
 type Registry map[string]Provider
 
 func (r Registry) Get(name string) (Provider, bool) {
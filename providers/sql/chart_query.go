@@ -0,0 +1,105 @@
+package sql
+
+import (
+	"fmt"
+	"slices"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+// buildChartQuery groups the filtered rows of the widget query by the chart
+// dimension and aggregates every measure per group.
+func buildChartQuery(widget config.Widget, req providers.DataRequest, driverName string) (string, []any, error) {
+	spec := widget.Chart
+	if spec.Dimension == "" {
+		return "", nil, fmt.Errorf("chart requires a dimension")
+	}
+	if len(spec.Measures) == 0 {
+		return "", nil, fmt.Errorf("chart requires at least one measure")
+	}
+
+	dimensionExpr, err := bucketExpr(spec.Bucket, spec.Dimension, driverName, unixDimension(widget, req))
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns := []string{fmt.Sprintf("%s AS %s", dimensionExpr, spec.Dimension)}
+	for _, measure := range spec.Measures {
+		expr, err := aggregateExpr(measure.Aggregate, measure.Column)
+		if err != nil {
+			return "", nil, err
+		}
+		columns = append(columns, fmt.Sprintf("%s AS %s", expr, measure.Key()))
+	}
+
+	base, _ := baseQuery(widget)
+	builder, err := applyConditions(sq.Select(columns...).From("("+base+") AS src"), widget, req, driverName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Positional references keep grouping on the bucketed expression even
+	// though its alias shadows the source column.
+	builder = builder.GroupBy("1")
+	if spec.Limit <= 0 {
+		return builder.OrderBy("1").PlaceholderFormat(sq.Question).ToSql()
+	}
+
+	// A limit keeps the groups with the largest first measure, which are
+	// then shown in dimension order.
+	top := builder.OrderBy("2 DESC").Limit(uint64(spec.Limit))
+	return sq.Select("*").FromSelect(top, "top").OrderBy("1").PlaceholderFormat(sq.Question).ToSql()
+}
+
+// unixDimension reports whether the chart dimension holds Unix timestamps:
+// it is typed unix_time or targeted by a datetime filter, whose values are
+// Unix timestamps.
+func unixDimension(widget config.Widget, req providers.DataRequest) bool {
+	dimension := widget.Chart.Dimension
+	if widget.Provider.SQL != nil && widget.Provider.SQL.Types[dimension] == config.UnixTime {
+		return true
+	}
+	for _, filter := range slices.Concat(widget.FilterSpecs(), req.PageFilters) {
+		if filter.Type == "datetime" && filter.Target == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+func bucketExpr(bucket config.TimeBucket, column string, driverName string, unix bool) (string, error) {
+	if bucket == "" {
+		return column, nil
+	}
+
+	switch driverName {
+	case "sqlite3":
+		if unix {
+			column += ", 'unixepoch'"
+		}
+		switch bucket {
+		case config.HourBucket:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00:00', %s)", column), nil
+		case config.DayBucket:
+			return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s)", column), nil
+		case config.WeekBucket:
+			return fmt.Sprintf("date(%s, 'weekday 0', '-6 days')", column), nil
+		case config.MonthBucket:
+			return fmt.Sprintf("strftime('%%Y-%%m-01', %s)", column), nil
+		}
+	case "postgres":
+		if unix {
+			column = fmt.Sprintf("to_timestamp(%s)", column)
+		}
+		switch bucket {
+		case config.HourBucket, config.DayBucket, config.WeekBucket, config.MonthBucket:
+			return fmt.Sprintf("date_trunc('%s', %s)", bucket, column), nil
+		}
+	default:
+		return "", fmt.Errorf("time buckets are not supported by %s", driverName)
+	}
+
+	return "", fmt.Errorf("unknown time bucket '%s'", bucket)
+}
@@ -0,0 +1,124 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func TestBuildChartQuery(t *testing.T) {
+	widget := config.Widget{
+		Type: config.ChartWidget,
+		Provider: config.ProviderSpec{
+			SQL: &config.SQLSpec{Query: "SELECT created_at, amount, region FROM orders ORDER BY created_at"},
+		},
+		Chart: &config.ChartSpec{
+			Kind:      config.LineChart,
+			Dimension: "created_at",
+			Bucket:    config.MonthBucket,
+			Measures: []config.MeasureSpec{
+				{Aggregate: config.CountAggregation},
+				{Column: "amount", Aggregate: config.SumAggregation},
+			},
+			Filters: []config.FilterSpec{
+				{ID: "region", Target: "region", Type: "select_one"},
+			},
+		},
+	}
+	req := providers.DataRequest{
+		Filters: []providers.Filter{{Name: "region", Values: []string{"eu"}}},
+	}
+
+	query, args, err := buildChartQuery(widget, req, "sqlite3")
+	require.NoError(t, err)
+	expectedQuery := "SELECT strftime('%Y-%m-01', created_at) AS created_at, COUNT(*) AS count, SUM(amount) AS sum_amount " +
+		"FROM (SELECT created_at, amount, region FROM orders) AS src WHERE region = ? GROUP BY 1 ORDER BY 1"
+	if query != expectedQuery {
+		t.Fatalf("expected query %q, got %q", expectedQuery, query)
+	}
+	if !reflect.DeepEqual(args, []any{"eu"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	query, _, err = buildChartQuery(widget, providers.DataRequest{}, "postgres")
+	require.NoError(t, err)
+	expectedQuery = "SELECT date_trunc('month', created_at) AS created_at, COUNT(*) AS count, SUM(amount) AS sum_amount " +
+		"FROM (SELECT created_at, amount, region FROM orders) AS src GROUP BY 1 ORDER BY 1"
+	if query != expectedQuery {
+		t.Fatalf("expected query %q, got %q", expectedQuery, query)
+	}
+
+	widget.Chart.Bucket = ""
+	widget.Chart.Limit = 5
+	query, args, err = buildChartQuery(widget, req, "sqlite3")
+	require.NoError(t, err)
+	expectedQuery = "SELECT * FROM (SELECT created_at AS created_at, COUNT(*) AS count, SUM(amount) AS sum_amount " +
+		"FROM (SELECT created_at, amount, region FROM orders) AS src WHERE region = ? GROUP BY 1 ORDER BY 2 DESC LIMIT 5) AS top ORDER BY 1"
+	if query != expectedQuery {
+		t.Fatalf("expected query %q, got %q", expectedQuery, query)
+	}
+	if !reflect.DeepEqual(args, []any{"eu"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	widget.Chart.Bucket = config.MonthBucket
+	_, _, err = buildChartQuery(widget, providers.DataRequest{}, "mysql")
+	require.EqualError(t, err, "time buckets are not supported by mysql")
+}
+
+func TestBuildChartQueryUnixTime(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// 2024-01-10, 2024-01-31 and 2024-02-11 as Unix timestamps.
+	_, err = db.Exec(`CREATE TABLE events (created_at INTEGER);
+		INSERT INTO events VALUES (1704844800), (1706659200), (1707609600)`)
+	require.NoError(t, err)
+
+	widget := config.Widget{
+		Type: config.ChartWidget,
+		Provider: config.ProviderSpec{
+			SQL: &config.SQLSpec{
+				Query: "SELECT created_at FROM events",
+				Types: map[string]config.DataType{"created_at": config.UnixTime},
+			},
+		},
+		Chart: &config.ChartSpec{
+			Dimension: "created_at",
+			Bucket:    config.MonthBucket,
+			Measures:  []config.MeasureSpec{{Aggregate: config.CountAggregation}},
+		},
+	}
+
+	query, _, err := buildChartQuery(widget, providers.DataRequest{}, "sqlite3")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT strftime('%Y-%m-01', created_at, 'unixepoch') AS created_at, COUNT(*) AS count "+
+		"FROM (SELECT created_at FROM events) AS src GROUP BY 1 ORDER BY 1", query)
+
+	var rows []struct {
+		Month string `db:"created_at"`
+		Count int    `db:"count"`
+	}
+	require.NoError(t, db.Select(&rows, query))
+	require.Equal(t, 2, len(rows))
+	require.Equal(t, "2024-01-01", rows[0].Month)
+	require.Equal(t, 2, rows[0].Count)
+	require.Equal(t, "2024-02-01", rows[1].Month)
+	require.Equal(t, 1, rows[1].Count)
+
+	query, _, err = buildChartQuery(widget, providers.DataRequest{}, "postgres")
+	require.NoError(t, err)
+	require.Contains(t, query, "date_trunc('month', to_timestamp(created_at)) AS created_at")
+
+	// A datetime filter on the dimension implies Unix timestamps as well.
+	widget.Provider.SQL.Types = nil
+	widget.Chart.Filters = []config.FilterSpec{{ID: "created", Type: "datetime", Target: "created_at"}}
+	query, _, err = buildChartQuery(widget, providers.DataRequest{}, "sqlite3")
+	require.NoError(t, err)
+	require.Contains(t, query, "strftime('%Y-%m-01', created_at, 'unixepoch')")
+}
@@ -12,17 +12,7 @@ import (
 
 func makeFilterCond(spec config.FilterSpec, value providers.Filter, dbName string,
 	typeHint *config.DataType) (sq.Sqlizer, error) {
-	operator := value.Operator
-
-	if operator == "" {
-		if len(spec.Operators) == 1 {
-			operator = spec.Operators[0]
-		} else if spec.Type == "select_multi" {
-			operator = "in"
-		} else {
-			operator = "eq"
-		}
-	}
+	operator := filterOperator(spec, value)
 
 	vals := make([]any, 0, len(value.Values))
 
@@ -68,6 +58,21 @@ func makeFilterCond(spec config.FilterSpec, value providers.Filter, dbName strin
 	return nil, fmt.Errorf("unknown operator in filter '%s':%s", value.Name, operator)
 }
 
+// filterOperator returns the operator of value, defaulting to the only
+// operator of the spec, "in" for multi-selects and "eq" otherwise.
+func filterOperator(spec config.FilterSpec, value providers.Filter) config.FilterOperator {
+	if value.Operator != "" {
+		return value.Operator
+	}
+	if len(spec.Operators) == 1 {
+		return spec.Operators[0]
+	}
+	if spec.Type == "select_multi" {
+		return config.InOperator
+	}
+	return config.EqOperator
+}
+
 func parseUnixValues(values []string) ([]int64, error) {
 	parsed := make([]int64, 0, len(values))
 	for _, value := range values {
@@ -90,15 +95,15 @@ func makeContainsFilterCond(spec config.FilterSpec, values []any, dbName string,
 	if typeHint != nil && *typeHint == config.JsonArray {
 		if dbName == "sqlite3" {
 			return sq.Expr(
-				fmt.Sprintf("EXISTS(select 1 from json_each(%s) where value like ?)", spec.Target),
-				fmt.Sprintf("%%%v%%", values[0]),
+				fmt.Sprintf("EXISTS(select 1 from json_each(%s) where value like ? ESCAPE '\\')", spec.Target),
+				likePattern(values[0]),
 			), nil
 		}
 	}
 
 	return sq.Expr(
-		fmt.Sprintf("%s %s ?", spec.Target, likeOperator(dbName)),
-		fmt.Sprintf("%%%v%%", values[0]),
+		fmt.Sprintf("%s %s ? ESCAPE '\\'", spec.Target, likeOperator(dbName)),
+		likePattern(values[0]),
 	), nil
 
 }
@@ -127,3 +132,11 @@ func likeOperator(driver string) string {
 	}
 	return "LIKE"
 }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern matches value anywhere in a LIKE condition with ESCAPE '\',
+// so that wildcards in value match literally.
+func likePattern(value any) string {
+	return "%" + likeEscaper.Replace(fmt.Sprint(value)) + "%"
+}
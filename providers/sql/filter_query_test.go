@@ -34,7 +34,7 @@ func TestMakeFilterCondDefaults(t *testing.T) {
 			spec:         config.FilterSpec{ID: "name", Target: "name", Type: "text", Operators: []config.FilterOperator{"contains"}},
 			filter:       providers.Filter{Name: "name", Values: []string{"ann"}},
 			dbName:       "sqlite3",
-			expectedSQL:  "SELECT * FROM src WHERE name LIKE ?",
+			expectedSQL:  "SELECT * FROM src WHERE name LIKE ? ESCAPE '\\'",
 			expectedArgs: []any{"%ann%"},
 		},
 		{
@@ -151,7 +151,7 @@ func TestMakeFilterCondOperators(t *testing.T) {
 			spec:         config.FilterSpec{ID: "name", Target: "name", Type: "text"},
 			filter:       providers.Filter{Name: "name", Operator: "contains", Values: []string{"ann"}},
 			dbName:       "sqlite3",
-			expectedSQL:  "SELECT * FROM src WHERE name LIKE ?",
+			expectedSQL:  "SELECT * FROM src WHERE name LIKE ? ESCAPE '\\'",
 			expectedArgs: []any{"%ann%"},
 		},
 		{
@@ -159,7 +159,7 @@ func TestMakeFilterCondOperators(t *testing.T) {
 			spec:         config.FilterSpec{ID: "name", Target: "name", Type: "text"},
 			filter:       providers.Filter{Name: "name", Operator: "contains", Values: []string{"ann"}},
 			dbName:       "postgres",
-			expectedSQL:  "SELECT * FROM src WHERE name ILIKE ?",
+			expectedSQL:  "SELECT * FROM src WHERE name ILIKE ? ESCAPE '\\'",
 			expectedArgs: []any{"%ann%"},
 		},
 		{
@@ -248,7 +248,7 @@ func TestMakeFilterCondJSONArrayContainsSQLite(t *testing.T) {
 		t.Fatalf("unexpected sql error: %v", err)
 	}
 
-	expectedSQL := "SELECT * FROM src WHERE EXISTS(select 1 from json_each(tags) where value like ?)"
+	expectedSQL := "SELECT * FROM src WHERE EXISTS(select 1 from json_each(tags) where value like ? ESCAPE '\\')"
 	if query != expectedSQL {
 		t.Fatalf("expected query %q, got %q", expectedSQL, query)
 	}
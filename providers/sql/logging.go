@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const redactedValue = "[redacted]"
+
+// logQuery logs the executed query when query logging is enabled. Filter,
+// search and cursor values are redacted unless all columns they target are
+// listed in the widget's log_args.
+func (p *Provider) logQuery(ctx context.Context, widget config.Widget, req providers.DataRequest, query string,
+	rows int, duration time.Duration, err error) {
+	if !p.logQueries || p.logger == nil || !p.logger.Enabled(ctx, p.logLevel) {
+		return
+	}
+
+	allowed := map[string]bool{}
+	for _, column := range widget.Provider.SQL.LogArgs {
+		allowed[column] = true
+	}
+
+	targets := map[string]string{}
+	for _, spec := range widget.FilterSpecs() {
+		targets[spec.ID] = spec.Target
+	}
+	for _, spec := range req.PageFilters {
+		targets[spec.ID] = spec.Target
+	}
+
+	filters := make([]string, 0, len(req.Filters))
+	for _, filter := range req.Filters {
+		key := filter.Name
+		if filter.Operator != "" {
+			key += "." + string(filter.Operator)
+		}
+		value := redactedValue
+		if target, ok := targets[filter.Name]; ok && allowed[target] {
+			value = fmt.Sprint(filter.Values)
+		}
+		filters = append(filters, key+"="+value)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("widget", widget.ID),
+		slog.String("query", query),
+		slog.Any("filters", filters),
+		slog.Int("rows", rows),
+		slog.Duration("duration", duration),
+	}
+	if req.Search != "" {
+		attrs = append(attrs, slog.String("search", redactUnless(req.Search, searchAllowed(widget, allowed))))
+	}
+	if req.Cursor != "" {
+		column := paginationColumn(widget.Provider.SQL.Pagination)
+		attrs = append(attrs, slog.String("cursor", redactUnless(req.Cursor, allowed[column])))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	p.logger.LogAttrs(ctx, p.logLevel, "sql query", attrs...)
+}
+
+func searchAllowed(widget config.Widget, allowed map[string]bool) bool {
+	if widget.Table == nil || widget.Table.Search == nil || len(widget.Table.Search.Columns) == 0 {
+		return false
+	}
+	for _, column := range widget.Table.Search.Columns {
+		if !allowed[column] {
+			return false
+		}
+	}
+	return true
+}
+
+func redactUnless(value string, allowed bool) string {
+	if allowed {
+		return value
+	}
+	return redactedValue
+}
@@ -5,28 +5,87 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/ankulikov/rapidmin/config"
 	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/tracing"
+)
+
+// postgresQueryCanceled is the SQLSTATE Postgres reports when a statement
+// is canceled, e.g. by statement_timeout.
+const postgresQueryCanceled = "57014"
+
+const (
+	pingAttempts   = 5
+	pingBackoff    = 200 * time.Millisecond
+	pingMaxBackoff = 5 * time.Second
 )
 
 type Provider struct {
-	db *sqlx.DB
+	db           *sqlx.DB
+	ownsDB       bool
+	queryTimeout time.Duration
+	logger       *slog.Logger
+	logQueries   bool
+	logLevel     slog.Level
+}
+
+type Option func(*Provider)
+
+// WithQueryTimeout sets the default timeout of widget queries. Widgets can
+// override it with sql.timeout.
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(p *Provider) {
+		p.queryTimeout = timeout
+	}
 }
 
-func New() *Provider {
-	return &Provider{}
+// WithLogger sets the logger used for query logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Provider) {
+		p.logger = logger
+	}
+}
+
+// WithQueryLogging logs every query at level.
+func WithQueryLogging(level slog.Level) Option {
+	return func(p *Provider) {
+		p.logQueries = true
+		p.logLevel = level
+	}
+}
+
+func New(opts ...Option) *Provider {
+	p := &Provider{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
-func NewWithDB(db *sqlx.DB) *Provider {
-	return &Provider{db: db}
+func NewWithDB(db *sqlx.DB, opts ...Option) *Provider {
+	p := New(opts...)
+	p.db = db
+	return p
 }
 
-func (p *Provider) Init(ctx context.Context, name string, providerConfig config.ProviderConfig) (err error) {
+func (p *Provider) Init(ctx context.Context, name string, providerConfig config.ProviderConfig) error {
+	if providerConfig.SQL != nil && p.queryTimeout == 0 {
+		p.queryTimeout = providerConfig.SQL.QueryTimeout
+	}
+	if providerConfig.SQL != nil && providerConfig.SQL.LogQueries != "" && !p.logQueries {
+		if err := p.logLevel.UnmarshalText([]byte(providerConfig.SQL.LogQueries)); err != nil {
+			return fmt.Errorf("sql provider %s log_queries: %w", name, err)
+		}
+		p.logQueries = true
+	}
+
 	if p.db != nil {
 		return nil
 	}
@@ -44,31 +103,123 @@ func (p *Provider) Init(ctx context.Context, name string, providerConfig config.
 		return fmt.Errorf("sql provider %s missing dsn", name)
 	}
 
-	p.db, err = sqlx.Open(driver, dsn)
+	db, err := sqlx.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	configurePool(db, providerConfig.SQL)
+
+	if err := pingWithRetry(ctx, db); err != nil {
+		_ = db.Close()
+		return fmt.Errorf("sql provider %s: %w", name, err)
+	}
+
+	p.db = db
+	p.ownsDB = true
+	return nil
+}
+
+// Ping checks that the database is reachable.
+func (p *Provider) Ping(ctx context.Context) error {
+	if p.db == nil {
+		return errors.New("sql provider not configured")
+	}
+	return p.db.PingContext(ctx)
+}
+
+// Close closes the database opened by Init. Databases passed to NewWithDB
+// are owned by the caller and stay open.
+func (p *Provider) Close() error {
+	if p.db == nil || !p.ownsDB {
+		return nil
+	}
+	return p.db.Close()
+}
+
+func configurePool(db *sqlx.DB, cfg *config.SQLProviderConfig) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+}
+
+// pingWithRetry waits for the database to become reachable, backing off
+// exponentially between attempts.
+func pingWithRetry(ctx context.Context, db *sqlx.DB) error {
+	backoff := pingBackoff
+	var err error
+	for attempt := 1; attempt <= pingAttempts; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt == pingAttempts {
+			break
+		}
 
-	return err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, pingMaxBackoff)
+	}
+	return fmt.Errorf("ping failed after %d attempts: %w", pingAttempts, err)
 }
 
 func (p *Provider) Fetch(ctx context.Context, widget config.Widget, req providers.DataRequest) (providers.DataResponse, error) {
 	if p.db == nil {
-		return providers.DataResponse{}, errors.New("sql provider not configured")
+		return providers.DataResponse{}, providers.NewError(providers.ConfigError, "sql provider not configured")
 	}
 	if widget.Provider.SQL == nil {
-		return providers.DataResponse{}, errors.New("sql provider missing query")
+		return providers.DataResponse{}, providers.NewError(providers.ConfigError, "sql provider missing query")
+	}
+	// A masked cursor column would send its raw values to the client as
+	// next_cursor; config validation rejects it as well.
+	if _, masked := providers.ColumnMasks(widget, req.Roles)[widget.PaginationColumn()]; masked {
+		return providers.DataResponse{}, providers.NewError(providers.ConfigError, "pagination column cannot be masked")
 	}
 
 	driverName := p.db.DriverName()
-	query, args, err := buildQuery(widget, req, driverName)
-	fmt.Println(query, args)
+	query, args, err := buildWidgetQuery(widget, req, driverName)
 	if err != nil {
-		return providers.DataResponse{}, err
+		return providers.DataResponse{}, buildError(err)
 	}
 	query = p.db.Rebind(query)
-	rows, err := p.db.QueryxContext(ctx, query, args...)
+
+	ctx, span := tracing.Start(ctx, "sql.query",
+		tracing.String("db.system", driverName),
+		tracing.String("db.statement", query),
+		tracing.String("widget.id", widget.ID),
+	)
+	defer span.End()
+
+	start := time.Now()
+	resp, err := p.execute(ctx, widget, req, query, args)
+	p.logQuery(ctx, widget, req, query, len(resp.Data), time.Since(start), err)
+	span.SetAttributes(tracing.Int("rows", len(resp.Data)))
+	span.RecordError(err)
+	return resp, err
+}
+
+func (p *Provider) execute(ctx context.Context, widget config.Widget, req providers.DataRequest, query string,
+	args []any) (providers.DataResponse, error) {
+	timeout := p.timeout(widget)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	rows, closeRows, err := p.queryRows(ctx, timeout, query, args)
 	if err != nil {
-		return providers.DataResponse{}, err
+		return providers.DataResponse{}, queryError(ctx, err)
 	}
-	defer rows.Close()
+	defer closeRows()
 
 	data := make([]map[string]any, 0)
 	nextCursor := ""
@@ -76,14 +227,14 @@ func (p *Provider) Fetch(ctx context.Context, widget config.Widget, req provider
 	for rows.Next() {
 		row := map[string]any{}
 		if err := rows.MapScan(row); err != nil {
-			return providers.DataResponse{}, err
+			return providers.DataResponse{}, queryError(ctx, err)
 		}
 		normalizeRow(row, widget.Provider.SQL.Types)
 		data = append(data, row)
 	}
 
 	if err := rows.Err(); err != nil {
-		return providers.DataResponse{}, err
+		return providers.DataResponse{}, queryError(ctx, err)
 	}
 
 	if req.Limit > 0 && len(data) > req.Limit {
@@ -91,12 +242,22 @@ func (p *Provider) Fetch(ctx context.Context, widget config.Widget, req provider
 		data = data[:req.Limit]
 	}
 
+	// Masks and formats run after the cursor is taken so that pagination
+	// keeps using raw values. Rows loaded for actions are masked but stay
+	// unformatted so they can be bound.
+	masks := providers.ColumnMasks(widget, req.Roles)
 	if len(data) > 0 {
 		cursorColumn := paginationColumn(widget.Provider.SQL.Pagination)
 		if cursorColumn != "" {
 			nextCursor = cursorValue(data[len(data)-1], cursorColumn)
 		}
 	}
+	for _, row := range data {
+		providers.MaskRow(row, masks)
+	}
+	if req.RowKey == nil {
+		providers.FormatRows(widget, data)
+	}
 
 	return providers.DataResponse{
 		Data:       data,
@@ -106,18 +267,122 @@ func (p *Provider) Fetch(ctx context.Context, widget config.Widget, req provider
 	}, nil
 }
 
-func buildQuery(widget config.Widget, req providers.DataRequest, driverName string) (string, []any, error) {
-	trimmed := strings.TrimSuffix(strings.TrimSpace(widget.Provider.SQL.Query), ";")
-	base, baseOrderBy := splitByOrderBy(trimmed)
-	builder := sq.Select("*").From("(" + base + ") AS src")
+// RunAction executes a row action statement with named parameters such as
+// :row.id bound from args, and reports the number of affected rows.
+func (p *Provider) RunAction(ctx context.Context, widget config.Widget, statement string, args map[string]any) (int64, error) {
+	if p.db == nil {
+		return 0, providers.NewError(providers.ConfigError, "sql provider not configured")
+	}
+	query, bound, err := sqlx.Named(statement, args)
+	if err != nil {
+		return 0, providers.WrapError(providers.ConfigError, "invalid action statement", err)
+	}
+	query = p.db.Rebind(query)
 
-	conds, err := buildFilterConditions(widget, req.Filters, driverName)
+	ctx, span := tracing.Start(ctx, "sql.exec",
+		tracing.String("db.system", p.db.DriverName()),
+		tracing.String("db.statement", query),
+		tracing.String("widget.id", widget.ID),
+	)
+	defer span.End()
+
+	if timeout := p.timeout(widget); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	result, err := p.db.ExecContext(ctx, query, bound...)
 	if err != nil {
-		return "", nil, err
+		span.RecordError(err)
+		return 0, queryError(ctx, err)
 	}
+	// Drivers that cannot count affected rows report zero.
+	affected, _ := result.RowsAffected()
+	span.SetAttributes(tracing.Int("rows", int(affected)))
+	return affected, nil
+}
 
-	for _, cond := range conds {
-		builder = builder.Where(cond)
+// timeout returns the sql.timeout of widget, falling back to the provider's
+// query timeout.
+func (p *Provider) timeout(widget config.Widget) time.Duration {
+	if widget.Provider.SQL != nil && widget.Provider.SQL.Timeout > 0 {
+		return widget.Provider.SQL.Timeout
+	}
+	return p.queryTimeout
+}
+
+// queryRows runs the query. On Postgres the timeout is also enforced by the
+// server through statement_timeout, so that the database stops working on the
+// query even if the connection is not interrupted.
+func (p *Provider) queryRows(ctx context.Context, timeout time.Duration, query string, args []any) (*sqlx.Rows, func(), error) {
+	if timeout <= 0 || p.db.DriverName() != "postgres" {
+		rows, err := p.db.QueryxContext(ctx, query, args...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return rows, func() { _ = rows.Close() }, nil
+	}
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	rows, err := tx.QueryxContext(ctx, query, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	return rows, func() {
+		_ = rows.Close()
+		_ = tx.Rollback()
+	}, nil
+}
+
+// buildError keeps typed errors such as invalid filters and reports anything
+// else as a widget configuration problem.
+func buildError(err error) error {
+	var typed *providers.Error
+	if errors.As(err, &typed) {
+		return typed
+	}
+	return providers.WrapError(providers.ConfigError, "invalid widget configuration", err)
+}
+
+// queryError hides database error details from users while keeping them for
+// logs; errors caused by an expired or canceled context keep their own codes.
+func queryError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return providers.AsError(fmt.Errorf("%w: %v", ctxErr, err))
+	}
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) && stateErr.SQLState() == postgresQueryCanceled {
+		return providers.WrapError(providers.TimeoutError, providers.TimeoutMessage, err)
+	}
+	return providers.WrapError(providers.DatabaseError, "database query failed", err)
+}
+
+func buildWidgetQuery(widget config.Widget, req providers.DataRequest, driverName string) (string, []any, error) {
+	switch {
+	case widget.Chart != nil:
+		return buildChartQuery(widget, req, driverName)
+	case widget.Stat != nil && widget.Stat.Aggregate != "":
+		return buildStatQuery(widget, req, driverName)
+	}
+	return buildQuery(widget, req, driverName)
+}
+
+func buildQuery(widget config.Widget, req providers.DataRequest, driverName string) (string, []any, error) {
+	base, baseOrderBy := baseQuery(widget)
+	builder, err := applyConditions(sq.Select("*").From("("+base+") AS src"), widget, req, driverName)
+	if err != nil {
+		return "", nil, err
+	}
+	if req.RowKey != nil {
+		builder = builder.Where(sq.Eq{req.RowKey.Column: req.RowKey.Value})
 	}
 
 	paginationCond, paginationOrder := buildPagination(widget.Provider.SQL.Pagination, req.Cursor)
@@ -145,13 +410,50 @@ func buildQuery(widget config.Widget, req providers.DataRequest, driverName stri
 	return query, args, nil
 }
 
-func buildFilterConditions(widget config.Widget, filters []providers.Filter, driverName string) ([]sq.Sqlizer, error) {
-	if widget.Table == nil {
+func baseQuery(widget config.Widget) (string, string) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(widget.Provider.SQL.Query), ";")
+	return splitByOrderBy(trimmed)
+}
+
+func applyConditions(builder sq.SelectBuilder, widget config.Widget, req providers.DataRequest,
+	driverName string) (sq.SelectBuilder, error) {
+	masks := providers.ColumnMasks(widget, req.Roles)
+	conds, err := buildFilterConditions(widget, req.PageFilters, req.Filters, masks, driverName)
+	if err != nil {
+		return builder, err
+	}
+
+	for _, cond := range conds {
+		builder = builder.Where(cond)
+	}
+
+	searchCond, err := buildSearchCondition(widget, req.Search, masks, driverName)
+	if err != nil {
+		return builder, err
+	}
+	if searchCond != nil {
+		builder = builder.Where(searchCond)
+	}
+
+	return builder, nil
+}
+
+// buildFilterConditions turns request filters into conditions. Columns in
+// masks cannot be filtered at all: even a contains or range filter would let
+// users probe their raw values.
+func buildFilterConditions(widget config.Widget, pageSpecs []config.FilterSpec, filters []providers.Filter,
+	masks map[string]config.MaskSpec, driverName string) ([]sq.Sqlizer, error) {
+	specs := widget.FilterSpecs()
+	if len(specs) == 0 && len(pageSpecs) == 0 {
 		return nil, nil
 	}
 
+	// Page filters take precedence over widget filters with the same ID.
 	filterIndex := map[string]config.FilterSpec{}
-	for _, filter := range widget.Table.Filters {
+	for _, filter := range specs {
+		filterIndex[filter.ID] = filter
+	}
+	for _, filter := range pageSpecs {
 		filterIndex[filter.ID] = filter
 	}
 
@@ -166,6 +468,10 @@ func buildFilterConditions(widget config.Widget, filters []providers.Filter, dri
 			continue
 		}
 
+		if _, masked := masks[spec.Target]; masked {
+			return nil, providers.InvalidFilter(filter.Name, "filtering is not allowed on a masked column")
+		}
+
 		var targetType *config.DataType
 		if _targetType, ok := widget.Provider.SQL.Types[spec.Target]; ok {
 			targetType = &_targetType
@@ -173,7 +479,7 @@ func buildFilterConditions(widget config.Widget, filters []providers.Filter, dri
 
 		cond, err := makeFilterCond(spec, filter, driverName, targetType)
 		if err != nil {
-			return nil, err
+			return nil, providers.InvalidFilter(filter.Name, err.Error())
 		}
 
 		conds = append(conds, cond)
@@ -254,7 +560,7 @@ func normalizeRow(row map[string]any, types map[string]config.DataType) {
 			value = string(bytes)
 			row[key] = value
 		}
-		if !hasType || targetType != config.JsonArray {
+		if !hasType || (targetType != config.JsonArray && targetType != config.JsonObject) {
 			continue
 		}
 		text, ok := value.(string)
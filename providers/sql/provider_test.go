@@ -1,11 +1,19 @@
 package sql
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 
 	"github.com/ankulikov/rapidmin/config"
@@ -31,7 +39,7 @@ func TestBuildFilterConditions(t *testing.T) {
 		{Name: "missing", Values: []string{"x"}},
 	}
 
-	conds, err := buildFilterConditions(widget, filters, "postgres")
+	conds, err := buildFilterConditions(widget, nil, filters, nil, "postgres")
 	require.NoError(t, err)
 
 	query, args, err := sq.Select("*").From("src").Where(sq.And(conds)).PlaceholderFormat(sq.Question).ToSql()
@@ -39,7 +47,7 @@ func TestBuildFilterConditions(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	expectedQuery := "SELECT * FROM src WHERE (name ILIKE ?)"
+	expectedQuery := "SELECT * FROM src WHERE (name ILIKE ? ESCAPE '\\')"
 	if query != expectedQuery {
 		t.Fatalf("expected query %q, got %q", expectedQuery, query)
 	}
@@ -83,7 +91,7 @@ func TestBuildQuery(t *testing.T) {
 	if !strings.HasPrefix(query, "SELECT * FROM (SELECT id, name FROM users) AS src") {
 		t.Fatalf("unexpected query: %q", query)
 	}
-	if !strings.Contains(query, "WHERE name ILIKE ? AND created_at < ?") {
+	if !strings.Contains(query, "WHERE name ILIKE ? ESCAPE '\\' AND created_at < ?") {
 		t.Fatalf("missing filters or pagination in query: %q", query)
 	}
 	if !strings.Contains(query, "ORDER BY created_at DESC") {
@@ -139,11 +147,13 @@ func TestNormalizeRowJSONType(t *testing.T) {
 	row := map[string]any{
 		"tags":  []byte(`["vip","active"]`),
 		"attrs": `{"tier":2}`,
+		"meta":  []byte(`{"plan":"pro"}`),
 		"name":  []byte(`Ann`),
 	}
 	types := map[string]config.DataType{
 		"tags":  config.JsonArray,
 		"attrs": config.JsonArray,
+		"meta":  config.JsonObject,
 	}
 
 	normalizeRow(row, types)
@@ -158,7 +168,232 @@ func TestNormalizeRowJSONType(t *testing.T) {
 		t.Fatalf("expected parsed attrs object, got %T: %v", row["attrs"], row["attrs"])
 	}
 
+	meta, ok := row["meta"].(map[string]any)
+	if !ok || meta["plan"] != "pro" {
+		t.Fatalf("expected parsed json_object, got %T: %v", row["meta"], row["meta"])
+	}
+
 	if row["name"] != "Ann" {
 		t.Fatalf("expected name to be string, got %T: %v", row["name"], row["name"])
 	}
 }
+
+func TestFetchQueryTimeout(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	widget := config.Widget{
+		ID: "slow",
+		Provider: config.ProviderSpec{
+			SQL: &config.SQLSpec{
+				Query: `WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000)
+					SELECT max(x) AS x FROM c`,
+				Timeout: 20 * time.Millisecond,
+			},
+		},
+	}
+
+	provider := NewWithDB(db, WithQueryTimeout(time.Hour))
+	start := time.Now()
+	_, err = provider.Fetch(context.Background(), widget, providers.DataRequest{})
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("query was not canceled in time")
+	}
+
+	var typed *providers.Error
+	if !errors.As(err, &typed) || typed.Code != providers.TimeoutError {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if typed.Message != providers.TimeoutMessage {
+		t.Fatalf("unexpected timeout message %q", typed.Message)
+	}
+}
+
+func TestInitConfiguresPoolAndCloses(t *testing.T) {
+	provider := New()
+	err := provider.Init(context.Background(), "db", config.ProviderConfig{
+		SQL: &config.SQLProviderConfig{
+			Driver:          "sqlite3",
+			DSN:             filepath.Join(t.TempDir(), "pool.db"),
+			MaxOpenConns:    3,
+			MaxIdleConns:    2,
+			ConnMaxLifetime: time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, provider.db.Stats().MaxOpenConnections)
+	require.NoError(t, provider.Ping(context.Background()))
+
+	require.NoError(t, provider.Close())
+	require.Error(t, provider.Ping(context.Background()))
+}
+
+func TestInitPingRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	provider := New()
+	err := provider.Init(ctx, "db", config.ProviderConfig{
+		SQL: &config.SQLProviderConfig{Driver: "sqlite3", DSN: filepath.Join(t.TempDir(), "ping.db")},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled error, got %v", err)
+	}
+	require.Nil(t, provider.db)
+}
+
+func TestFetchLogsRedactedQuery(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER, name TEXT, email TEXT)`)
+	require.NoError(t, err)
+
+	widget := config.Widget{
+		ID: "users",
+		Provider: config.ProviderSpec{
+			SQL: &config.SQLSpec{Query: "SELECT id, name, email FROM users", LogArgs: []string{"name"}},
+		},
+		Table: &config.TableSpec{
+			Filters: []config.FilterSpec{
+				{ID: "name", Target: "name"},
+				{ID: "email", Target: "email"},
+			},
+			Search: &config.SearchSpec{Columns: []string{"email"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	provider := NewWithDB(db, WithLogger(logger), WithQueryLogging(slog.LevelDebug))
+	_, err = provider.Fetch(context.Background(), widget, providers.DataRequest{
+		Limit:  10,
+		Search: "secret-search",
+		Filters: []providers.Filter{
+			{Name: "name", Values: []string{"Ann"}},
+			{Name: "email", Values: []string{"ann@example.com"}},
+		},
+	})
+	require.NoError(t, err)
+
+	out := buf.String()
+	for _, want := range []string{`msg="sql query"`, "widget=users", "name=[Ann]", "email=[redacted]", "search=[redacted]"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log missing %q:\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"ann@example.com", "secret-search"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log leaks %q:\n%s", secret, out)
+		}
+	}
+}
+
+func TestFetchMasksColumns(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER, email TEXT);
+		INSERT INTO users VALUES (1, 'ann@example.com')`)
+	require.NoError(t, err)
+
+	widget := config.Widget{
+		ID:       "users",
+		Provider: config.ProviderSpec{SQL: &config.SQLSpec{Query: "SELECT id, email FROM users"}},
+		Table: &config.TableSpec{
+			Columns: []config.ColumnSpec{
+				{ID: "id"},
+				{ID: "email", Mask: &config.MaskSpec{Type: config.PartialMask, ExemptRoles: []string{"support"}}},
+			},
+			Filters: []config.FilterSpec{
+				{ID: "email", Target: "email", Operators: []config.FilterOperator{"eq", "contains"}},
+			},
+		},
+	}
+	provider := NewWithDB(db)
+
+	resp, err := provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, "***@example.com", resp.Data[0]["email"])
+
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10, Roles: []string{"support"}})
+	require.NoError(t, err)
+	require.Equal(t, "ann@example.com", resp.Data[0]["email"])
+
+	exact := []providers.Filter{{Name: "email", Values: []string{"ann@example.com"}}}
+	_, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10, Filters: exact})
+	if typed := providers.AsError(err); err == nil || typed.Code != providers.InvalidFilterError || typed.Field != "email" {
+		t.Fatalf("expected exact match on masked column to be rejected, got %v", err)
+	}
+
+	_, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10, Filters: exact, Roles: []string{"support"}})
+	require.NoError(t, err)
+
+	contains := []providers.Filter{{Name: "email", Operator: config.ContainsOperator, Values: []string{"example"}}}
+	_, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10, Filters: contains})
+	if typed := providers.AsError(err); err == nil || typed.Code != providers.InvalidFilterError || typed.Field != "email" {
+		t.Fatalf("expected contains on masked column to be rejected, got %v", err)
+	}
+
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 10, Filters: contains, Roles: []string{"support"}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Data))
+
+	_, err = db.Exec(`INSERT INTO users VALUES (2, 'bob@example.com')`)
+	require.NoError(t, err)
+	widget.Provider.SQL.Pagination = &config.PaginationSpec{Column: "email", Order: "asc"}
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 1})
+	if typed := providers.AsError(err); err == nil || typed.Code != providers.ConfigError || resp.NextCursor != "" {
+		t.Fatalf("expected a masked pagination column to be refused, got %v (cursor %q)", err, resp.NextCursor)
+	}
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 1, Roles: []string{"support"}})
+	require.NoError(t, err)
+	require.Equal(t, "ann@example.com", resp.NextCursor)
+
+	widget.Provider.SQL.Pagination = &config.PaginationSpec{Column: "id", Order: "asc"}
+	widget.Provider.SQL.Query = "SELECT id, email FROM users ORDER BY id"
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 1})
+	require.NoError(t, err)
+	resp, err = provider.Fetch(context.Background(), widget, providers.DataRequest{Limit: 1, Cursor: resp.NextCursor})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), resp.Data[0]["id"])
+	require.Equal(t, "***@example.com", resp.Data[0]["email"])
+}
+
+func TestRunAction(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.Exec(`CREATE TABLE orders (id INTEGER, status TEXT);
+		INSERT INTO orders VALUES (1, 'paid'), (2, 'paid')`)
+	require.NoError(t, err)
+
+	provider := NewWithDB(db)
+	affected, err := provider.RunAction(context.Background(), config.Widget{ID: "orders"},
+		"UPDATE orders SET status = :param.status WHERE id = :row.id",
+		map[string]any{"row.id": int64(2), "param.status": "refunded"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	var status string
+	require.NoError(t, db.Get(&status, "SELECT status FROM orders WHERE id = 2"))
+	require.Equal(t, "refunded", status)
+
+	_, err = provider.RunAction(context.Background(), config.Widget{ID: "orders"},
+		"UPDATE missing SET status = :param.status", map[string]any{"param.status": "x"})
+	if typed := providers.AsError(err); err == nil || typed.Code != providers.DatabaseError {
+		t.Fatalf("expected database error, got %v", err)
+	}
+
+	// Actions use the sql.timeout of their widget like its queries.
+	widget := config.Widget{ID: "orders", Provider: config.ProviderSpec{
+		SQL: &config.SQLSpec{Timeout: 20 * time.Millisecond},
+	}}
+	_, err = NewWithDB(db, WithQueryTimeout(time.Hour)).RunAction(context.Background(), widget,
+		`UPDATE orders SET status = (WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000)
+			SELECT max(x) FROM c)`, nil)
+	if typed := providers.AsError(err); err == nil || typed.Code != providers.TimeoutError {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
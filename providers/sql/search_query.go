@@ -0,0 +1,113 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const defaultSearchLanguage = "simple"
+
+// buildSearchCondition matches search against the widget's search columns.
+// Columns in masks are left out so that users cannot probe their raw values;
+// full-text indexes cannot leave columns out and are unavailable to users
+// who see masked columns.
+func buildSearchCondition(widget config.Widget, search string, masks map[string]config.MaskSpec,
+	dbName string) (sq.Sqlizer, error) {
+	search = strings.TrimSpace(search)
+	if search == "" || widget.Table == nil || widget.Table.Search == nil {
+		return nil, nil
+	}
+
+	spec := unmaskedSearch(widget.Table.Search, masks)
+	if len(masks) > 0 && (spec.Engine == config.FTS5SearchEngine || len(spec.Columns) == 0) {
+		return nil, providers.InvalidFilter("q", "search is not allowed on masked columns")
+	}
+	switch spec.Engine {
+	case "", config.LikeSearchEngine:
+		return makeLikeSearchCond(spec, search, dbName)
+	case config.FTS5SearchEngine:
+		return makeFTS5SearchCond(spec, search, dbName)
+	case config.TSVectorSearchEngine:
+		return makeTSVectorSearchCond(spec, search, dbName)
+	}
+
+	return nil, fmt.Errorf("unknown search engine '%s'", spec.Engine)
+}
+
+// unmaskedSearch returns a copy of spec without the columns in masks.
+func unmaskedSearch(spec *config.SearchSpec, masks map[string]config.MaskSpec) *config.SearchSpec {
+	if len(masks) == 0 {
+		return spec
+	}
+	unmasked := *spec
+	unmasked.Columns = nil
+	for _, column := range spec.Columns {
+		if _, masked := masks[column]; !masked {
+			unmasked.Columns = append(unmasked.Columns, column)
+		}
+	}
+	return &unmasked
+}
+
+func makeLikeSearchCond(spec *config.SearchSpec, search string, dbName string) (sq.Sqlizer, error) {
+	if len(spec.Columns) == 0 {
+		return nil, fmt.Errorf("search requires at least one column")
+	}
+
+	pattern := likePattern(search)
+	conds := make(sq.Or, 0, len(spec.Columns))
+	for _, column := range spec.Columns {
+		conds = append(conds, sq.Expr(fmt.Sprintf("%s %s ? ESCAPE '\\'", column, likeOperator(dbName)), pattern))
+	}
+
+	return conds, nil
+}
+
+func makeFTS5SearchCond(spec *config.SearchSpec, search string, dbName string) (sq.Sqlizer, error) {
+	if dbName != "sqlite3" {
+		return nil, fmt.Errorf("search engine '%s' is not supported by %s", spec.Engine, dbName)
+	}
+	if spec.Table == "" || spec.Key == "" {
+		return nil, fmt.Errorf("search engine '%s' requires table and key", spec.Engine)
+	}
+
+	return sq.Expr(
+		fmt.Sprintf("%s IN (SELECT rowid FROM %s WHERE %s MATCH ?)", spec.Key, spec.Table, spec.Table),
+		fts5Query(search),
+	), nil
+}
+
+func makeTSVectorSearchCond(spec *config.SearchSpec, search string, dbName string) (sq.Sqlizer, error) {
+	if dbName != "postgres" {
+		return nil, fmt.Errorf("search engine '%s' is not supported by %s", spec.Engine, dbName)
+	}
+	if len(spec.Columns) == 0 {
+		return nil, fmt.Errorf("search requires at least one column")
+	}
+
+	language := spec.Language
+	if language == "" {
+		language = defaultSearchLanguage
+	}
+
+	return sq.Expr(
+		fmt.Sprintf("to_tsvector(?::regconfig, concat_ws(' ', %s)) @@ plainto_tsquery(?::regconfig, ?)",
+			strings.Join(spec.Columns, ", ")),
+		language, language, search,
+	), nil
+}
+
+// fts5Query quotes every term so that user input is matched literally
+// instead of being parsed as FTS5 query syntax.
+func fts5Query(search string) string {
+	terms := strings.Fields(search)
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	return strings.Join(quoted, " ")
+}
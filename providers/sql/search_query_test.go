@@ -0,0 +1,176 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+func TestBuildSearchCondition(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          *config.SearchSpec
+		masks         map[string]config.MaskSpec
+		search        string
+		dbName        string
+		expectedSQL   string
+		expectedArgs  []any
+		expectedError string
+	}{
+		{
+			name:         "like sqlite",
+			spec:         &config.SearchSpec{Columns: []string{"name", "email"}},
+			search:       "ann",
+			dbName:       "sqlite3",
+			expectedSQL:  "SELECT * FROM src WHERE (name LIKE ? ESCAPE '\\' OR email LIKE ? ESCAPE '\\')",
+			expectedArgs: []any{"%ann%", "%ann%"},
+		},
+		{
+			name:         "like postgres",
+			spec:         &config.SearchSpec{Columns: []string{"name"}, Engine: config.LikeSearchEngine},
+			search:       " ann ",
+			dbName:       "postgres",
+			expectedSQL:  "SELECT * FROM src WHERE (name ILIKE ? ESCAPE '\\')",
+			expectedArgs: []any{"%ann%"},
+		},
+		{
+			name:         "like escapes wildcards",
+			spec:         &config.SearchSpec{Columns: []string{"code"}},
+			search:       `100%_\`,
+			dbName:       "sqlite3",
+			expectedSQL:  "SELECT * FROM src WHERE (code LIKE ? ESCAPE '\\')",
+			expectedArgs: []any{`%100\%\_\\%`},
+		},
+		{
+			name:         "fts5",
+			spec:         &config.SearchSpec{Engine: config.FTS5SearchEngine, Table: "users_fts", Key: "id"},
+			search:       `ann "smith`,
+			dbName:       "sqlite3",
+			expectedSQL:  "SELECT * FROM src WHERE id IN (SELECT rowid FROM users_fts WHERE users_fts MATCH ?)",
+			expectedArgs: []any{`"ann" """smith"`},
+		},
+		{
+			name:         "tsvector",
+			spec:         &config.SearchSpec{Engine: config.TSVectorSearchEngine, Columns: []string{"name", "email"}},
+			search:       "ann",
+			dbName:       "postgres",
+			expectedSQL:  "SELECT * FROM src WHERE to_tsvector(?::regconfig, concat_ws(' ', name, email)) @@ plainto_tsquery(?::regconfig, ?)",
+			expectedArgs: []any{"simple", "simple", "ann"},
+		},
+		{
+			name:          "fts5 on postgres",
+			spec:          &config.SearchSpec{Engine: config.FTS5SearchEngine, Table: "users_fts", Key: "id"},
+			search:        "ann",
+			dbName:        "postgres",
+			expectedError: "search engine 'fts5' is not supported by postgres",
+		},
+		{
+			name:         "masked column left out",
+			spec:         &config.SearchSpec{Columns: []string{"name", "email"}},
+			masks:        map[string]config.MaskSpec{"email": {Type: config.PartialMask}},
+			search:       "ann",
+			dbName:       "sqlite3",
+			expectedSQL:  "SELECT * FROM src WHERE (name LIKE ? ESCAPE '\\')",
+			expectedArgs: []any{"%ann%"},
+		},
+		{
+			name:          "only masked columns",
+			spec:          &config.SearchSpec{Columns: []string{"email"}},
+			masks:         map[string]config.MaskSpec{"email": {Type: config.PartialMask}},
+			search:        "ann",
+			dbName:        "sqlite3",
+			expectedError: "search is not allowed on masked columns",
+		},
+		{
+			name:          "fts5 with masked columns",
+			spec:          &config.SearchSpec{Engine: config.FTS5SearchEngine, Table: "users_fts", Key: "id"},
+			masks:         map[string]config.MaskSpec{"email": {Type: config.PartialMask}},
+			search:        "ann",
+			dbName:        "sqlite3",
+			expectedError: "search is not allowed on masked columns",
+		},
+		{
+			name:          "no columns",
+			spec:          &config.SearchSpec{},
+			search:        "ann",
+			dbName:        "sqlite3",
+			expectedError: "search requires at least one column",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			widget := config.Widget{Table: &config.TableSpec{Search: tc.spec}}
+			cond, err := buildSearchCondition(widget, tc.search, tc.masks, tc.dbName)
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Fatalf("expected error %q, got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			query, args, err := sq.Select("*").From("src").Where(cond).PlaceholderFormat(sq.Question).ToSql()
+			if err != nil {
+				t.Fatalf("unexpected sql error: %v", err)
+			}
+
+			if query != tc.expectedSQL {
+				t.Fatalf("expected query %q, got %q", tc.expectedSQL, query)
+			}
+			if !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Fatalf("expected args %v, got %v", tc.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestBuildSearchConditionEmpty(t *testing.T) {
+	widget := config.Widget{Table: &config.TableSpec{Search: &config.SearchSpec{Columns: []string{"name"}}}}
+	cond, err := buildSearchCondition(widget, "   ", nil, "sqlite3")
+	if err != nil || cond != nil {
+		t.Fatalf("expected no condition, got %v, %v", cond, err)
+	}
+
+	cond, err = buildSearchCondition(config.Widget{Table: &config.TableSpec{}}, "ann", nil, "sqlite3")
+	if err != nil || cond != nil {
+		t.Fatalf("expected no condition without search spec, got %v, %v", cond, err)
+	}
+}
+
+func TestLikeSearchMatchesWildcardsLiterally(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(`CREATE TABLE codes (code TEXT);
+		INSERT INTO codes VALUES ('100%'), ('1000'), ('a_b'), ('axb'), ('c\d')`); err != nil {
+		t.Fatalf("seed db: %v", err)
+	}
+
+	widget := config.Widget{Table: &config.TableSpec{Search: &config.SearchSpec{Columns: []string{"code"}}}}
+	for search, expected := range map[string][]string{"100%": {"100%"}, "a_b": {"a_b"}, `c\d`: {`c\d`}} {
+		cond, err := buildSearchCondition(widget, search, nil, "sqlite3")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", search, err)
+		}
+		query, args, err := sq.Select("code").From("codes").Where(cond).ToSql()
+		if err != nil {
+			t.Fatalf("%s: unexpected sql error: %v", search, err)
+		}
+		var codes []string
+		if err := db.Select(&codes, query, args...); err != nil {
+			t.Fatalf("%s: query: %v", search, err)
+		}
+		if !reflect.DeepEqual(codes, expected) {
+			t.Fatalf("%s: expected %v, got %v", search, expected, codes)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+// buildStatQuery aggregates the value and previous columns of a stat widget
+// over the filtered rows of its query.
+func buildStatQuery(widget config.Widget, req providers.DataRequest, driverName string) (string, []any, error) {
+	spec := widget.Stat
+
+	valueExpr, err := aggregateExpr(spec.Aggregate, spec.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	columns := []string{fmt.Sprintf("%s AS %s", valueExpr, spec.ValueColumn())}
+
+	if spec.Previous != "" {
+		previousExpr, err := aggregateExpr(spec.Aggregate, spec.Previous)
+		if err != nil {
+			return "", nil, err
+		}
+		columns = append(columns, fmt.Sprintf("%s AS %s", previousExpr, spec.Previous))
+	}
+
+	base, _ := baseQuery(widget)
+	builder, err := applyConditions(sq.Select(columns...).From("("+base+") AS src"), widget, req, driverName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return builder.PlaceholderFormat(sq.Question).ToSql()
+}
+
+func aggregateExpr(aggregation config.Aggregation, column string) (string, error) {
+	if column == "" {
+		column = "*"
+	}
+
+	switch aggregation {
+	case config.CountAggregation:
+		return fmt.Sprintf("COUNT(%s)", column), nil
+	case config.SumAggregation, config.AvgAggregation, config.MinAggregation, config.MaxAggregation:
+		if column == "*" {
+			return "", fmt.Errorf("aggregation '%s' requires a column", aggregation)
+		}
+		return fmt.Sprintf("%s(%s)", strings.ToUpper(string(aggregation)), column), nil
+	}
+
+	return "", fmt.Errorf("unknown aggregation '%s'", aggregation)
+}
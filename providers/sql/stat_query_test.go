@@ -0,0 +1,54 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func TestBuildStatQuery(t *testing.T) {
+	widget := config.Widget{
+		Type: config.StatWidget,
+		Provider: config.ProviderSpec{
+			SQL: &config.SQLSpec{Query: "SELECT amount, prev_amount, region FROM orders ORDER BY id"},
+		},
+		Stat: &config.StatSpec{
+			Value:     "amount",
+			Previous:  "prev_amount",
+			Aggregate: config.SumAggregation,
+			Filters: []config.FilterSpec{
+				{ID: "region", Target: "region", Type: "select_one"},
+			},
+		},
+	}
+	req := providers.DataRequest{
+		Filters: []providers.Filter{{Name: "region", Values: []string{"eu"}}},
+	}
+
+	query, args, err := buildStatQuery(widget, req, "sqlite3")
+	require.NoError(t, err)
+
+	expectedQuery := "SELECT SUM(amount) AS amount, SUM(prev_amount) AS prev_amount " +
+		"FROM (SELECT amount, prev_amount, region FROM orders) AS src WHERE region = ?"
+	if query != expectedQuery {
+		t.Fatalf("expected query %q, got %q", expectedQuery, query)
+	}
+	if !reflect.DeepEqual(args, []any{"eu"}) {
+		t.Fatalf("unexpected args %v", args)
+	}
+
+	widget.Stat = &config.StatSpec{Aggregate: config.CountAggregation}
+	query, _, err = buildStatQuery(widget, providers.DataRequest{}, "sqlite3")
+	require.NoError(t, err)
+	if query != "SELECT COUNT(*) AS value FROM (SELECT amount, prev_amount, region FROM orders) AS src" {
+		t.Fatalf("unexpected count query %q", query)
+	}
+
+	widget.Stat = &config.StatSpec{Aggregate: config.AvgAggregation}
+	_, _, err = buildStatQuery(widget, providers.DataRequest{}, "sqlite3")
+	require.EqualError(t, err, "aggregation 'avg' requires a column")
+}
@@ -0,0 +1,325 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/tracing"
+)
+
+const (
+	maxActionBody         = 64 << 10
+	maxWebhookResponse    = 64 << 10
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+var (
+	errActionForbidden     = providers.NewError(providers.ForbiddenError, "action not permitted")
+	errActionUnconfirmed   = providers.NewError(providers.InvalidRequestError, "action requires confirmation")
+	errActionUnsupported   = providers.NewError(providers.ConfigError, "provider does not support actions")
+	errActionRowNotFound   = providers.NewError(providers.NotFoundError, "row not found")
+	errWebhookFailed       = providers.NewError(providers.UpstreamError, "action webhook failed")
+	webhookTemplatePattern = regexp.MustCompile(`\{\{\s*((?:row|param)\.[A-Za-z0-9_]+)\s*}}`)
+)
+
+// WithHTTPClient sets the client used to call action webhooks.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Server) {
+		s.httpClient = client
+	}
+}
+
+type actionRequest struct {
+	// Key is the row key value. The row itself is loaded on the server.
+	Key       any               `json:"key"`
+	Params    map[string]string `json:"params"`
+	Confirmed bool              `json:"confirmed"`
+}
+
+type actionResponse struct {
+	OK           bool   `json:"ok"`
+	RowsAffected int64  `json:"rows_affected"`
+	Message      string `json:"message,omitempty"`
+}
+
+type webhookPayload struct {
+	Action string            `json:"action"`
+	Widget string            `json:"widget"`
+	User   string            `json:"user,omitempty"`
+	Row    map[string]any    `json:"row"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// handleRowAction runs a row action of a table widget and drops the
+// widget's cached data once it succeeded.
+func (s *Server) handleRowAction(w http.ResponseWriter, r *http.Request) {
+	widget, ok := s.findWidget(r.PathValue("id"))
+	if !ok || widget.Table == nil {
+		s.writeError(w, errNotFound)
+		return
+	}
+	if !widgetAllowed(r.Context(), widget) {
+		s.writeError(w, errWidgetForbidden)
+		return
+	}
+	idx := slices.IndexFunc(widget.Table.RowActions, func(a config.RowAction) bool {
+		return a.ID == r.PathValue("action")
+	})
+	if idx < 0 {
+		s.writeError(w, errNotFound)
+		return
+	}
+	action := widget.Table.RowActions[idx]
+
+	id, _ := IdentityFromContext(r.Context())
+	if !id.HasScope(WriteScope) {
+		s.auditAction(r.Context(), widget, action, nil, 0, 0, errWriteScope)
+		s.writeError(w, errWriteScope)
+		return
+	}
+	if len(action.Roles) > 0 && !id.HasRole(action.Roles...) {
+		s.auditAction(r.Context(), widget, action, nil, 0, 0, errActionForbidden)
+		s.writeError(w, errActionForbidden)
+		return
+	}
+
+	var req actionRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxActionBody))
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		err := providers.WrapError(providers.InvalidRequestError, "invalid action request", err)
+		s.auditAction(r.Context(), widget, action, nil, 0, 0, err)
+		s.writeError(w, err)
+		return
+	}
+	if action.Confirm != "" && !req.Confirmed {
+		s.auditAction(r.Context(), widget, action, map[string]any{"key": req.Key}, 0, 0, errActionUnconfirmed)
+		s.writeError(w, errActionUnconfirmed)
+		return
+	}
+	row, err := s.loadActionRow(r.Context(), widget, req.Key, r.URL.Query())
+	if err != nil {
+		s.auditAction(r.Context(), widget, action, map[string]any{"key": req.Key}, 0, 0, err)
+		s.writeError(w, err)
+		return
+	}
+	bindings, err := actionBindings(action, row, req.Params)
+	if err != nil {
+		s.auditAction(r.Context(), widget, action, map[string]any{"key": req.Key}, 0, 0, err)
+		s.writeError(w, err)
+		return
+	}
+
+	ctx, span := tracing.Start(r.Context(), "action.run",
+		tracing.String("widget.id", widget.ID),
+		tracing.String("action.id", action.ID),
+	)
+	start := time.Now()
+	var resp actionResponse
+	if action.SQL != nil {
+		resp, err = s.runSQLAction(ctx, widget, action, bindings)
+	} else {
+		resp, err = s.runWebhookAction(ctx, widget, action, id, row, req.Params, bindings)
+	}
+	duration := time.Since(start)
+	span.RecordError(err)
+	span.End()
+	s.auditAction(ctx, widget, action, bindings, int(resp.RowsAffected), duration, err)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if provider, ok := s.providers.Get(widget.Provider.Name); ok {
+		if purger, ok := provider.(providers.Purger); ok {
+			purger.Purge(widget.ID)
+		}
+	}
+	resp.OK = true
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// loadActionRow loads the row with the given key through the widget's own
+// query, with the caller's filters, roles and masks applied, so that actions
+// only run on rows the caller can see.
+func (s *Server) loadActionRow(ctx context.Context, widget config.Widget, key any, query url.Values) (map[string]any, error) {
+	var value string
+	switch v := key.(type) {
+	case string:
+		value = v
+	case json.Number:
+		value = v.String()
+	}
+	column := widget.Table.RowKeyColumn()
+	if value == "" {
+		return nil, invalidAction("key", "missing row key")
+	}
+
+	provider, ok := s.providers.Get(widget.Provider.Name)
+	if !ok {
+		return nil, errUnknownProvider
+	}
+	req := s.dataRequest(ctx, widget, query)
+	req.Limit, req.Cursor = 1, ""
+	req.RowKey = &providers.RowKey{Column: column, Value: value}
+	data, err := provider.Fetch(ctx, widget, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Data) == 0 {
+		return nil, errActionRowNotFound
+	}
+	return data.Data[0], nil
+}
+
+// actionBindings validates the parameters against the action and returns
+// them with the row values, keyed "row.<column>" and "param.<id>".
+func actionBindings(action config.RowAction, row map[string]any, params map[string]string) (map[string]any, error) {
+	bindings := make(map[string]any, len(row)+len(params))
+	for column, value := range row {
+		bindings["row."+column] = value
+	}
+
+	for _, param := range action.Params {
+		field := "param." + param.ID
+		value := strings.TrimSpace(params[param.ID])
+		if value == "" {
+			if param.Required {
+				return nil, invalidAction(field, param.ID+" is required")
+			}
+			bindings[field] = nil
+			continue
+		}
+		switch param.Type {
+		case "number":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, invalidAction(field, param.ID+" must be a number")
+			}
+			bindings[field] = number
+			continue
+		case "select_one":
+			if !slices.ContainsFunc(param.Values, func(v config.ValueOption) bool { return v.Value == value }) {
+				return nil, invalidAction(field, param.ID+" has an unknown value")
+			}
+		}
+		bindings[field] = value
+	}
+
+	var required []string
+	if action.SQL != nil {
+		required = action.SQL.Bindings()
+	} else {
+		for _, match := range webhookTemplatePattern.FindAllStringSubmatch(action.Webhook.URL, -1) {
+			required = append(required, match[1])
+		}
+	}
+	for _, name := range required {
+		if _, ok := bindings[name]; !ok && strings.HasPrefix(name, "row.") {
+			return nil, providers.NewError(providers.ConfigError, "action binds unknown column "+strings.TrimPrefix(name, "row."))
+		}
+	}
+	return bindings, nil
+}
+
+func invalidAction(field, message string) *providers.Error {
+	e := providers.NewError(providers.InvalidRequestError, message)
+	e.Field = field
+	return e
+}
+
+func (s *Server) runSQLAction(ctx context.Context, widget config.Widget, action config.RowAction,
+	bindings map[string]any) (actionResponse, error) {
+	name := action.SQL.Provider
+	if name == "" {
+		name = widget.Provider.Name
+	}
+	provider, ok := s.providers.Get(name)
+	if !ok {
+		return actionResponse{}, errUnknownProvider
+	}
+	runner, ok := provider.(providers.ActionRunner)
+	if !ok {
+		return actionResponse{}, errActionUnsupported
+	}
+	affected, err := runner.RunAction(ctx, widget, action.SQL.Statement, bindings)
+	return actionResponse{RowsAffected: affected}, err
+}
+
+func (s *Server) runWebhookAction(ctx context.Context, widget config.Widget, action config.RowAction, id Identity,
+	row map[string]any, params map[string]string, bindings map[string]any) (actionResponse, error) {
+	hook := action.Webhook
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(webhookPayload{
+		Action: action.ID,
+		Widget: widget.ID,
+		User:   id.Subject,
+		Row:    row,
+		Params: params,
+	})
+	if err != nil {
+		return actionResponse{}, err
+	}
+	target := webhookTemplatePattern.ReplaceAllStringFunc(hook.URL, func(match string) string {
+		value := bindings[webhookTemplatePattern.FindStringSubmatch(match)[1]]
+		if value == nil {
+			return ""
+		}
+		return url.PathEscape(fmt.Sprint(value))
+	})
+	method := strings.ToUpper(hook.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return actionResponse{}, providers.WrapError(providers.ConfigError, "invalid action webhook", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, value := range hook.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	tracing.Inject(ctx, httpReq.Header)
+
+	client := s.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return actionResponse{}, providers.AsError(fmt.Errorf("%w: %v", ctx.Err(), err))
+		}
+		return actionResponse{}, providers.WrapError(providers.UpstreamError, errWebhookFailed.Message, err)
+	}
+	defer httpResp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(httpResp.Body, maxWebhookResponse))
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return actionResponse{}, providers.WrapError(providers.UpstreamError, errWebhookFailed.Message,
+			fmt.Errorf("%s %s: status %d", method, hook.URL, httpResp.StatusCode))
+	}
+	var resp actionResponse
+	_ = json.Unmarshal(data, &resp)
+	return actionResponse{RowsAffected: resp.RowsAffected, Message: resp.Message}, nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+	sqlprovider "github.com/ankulikov/rapidmin/providers/sql"
+	"github.com/ankulikov/rapidmin/tracing"
+)
+
+func TestServerRowActions(t *testing.T) {
+	db := setupSQLiteDB(t)
+
+	var hook struct {
+		path        string
+		traceparent string
+		secret      string
+		payload     webhookPayload
+	}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hook.path, hook.traceparent, hook.secret = r.URL.Path, r.Header.Get("traceparent"), r.Header.Get("X-Secret")
+		_ = json.NewDecoder(r.Body).Decode(&hook.payload)
+		if strings.HasSuffix(r.URL.Path, "/1") {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		_, _ = io.WriteString(w, `{"message":"reset sent"}`)
+	}))
+	t.Cleanup(upstream.Close)
+
+	cfg := sampleConfig()
+	users := &cfg.Pages[0].Widgets[0]
+	// User 3 is outside the widget's result set.
+	users.Provider.SQL.Query = `SELECT id, name, email, age, created_at, tag FROM users WHERE id < 3 ORDER BY id ASC`
+	users.Table.Columns[2].Mask = &config.MaskSpec{Type: config.FullMask, ExemptRoles: []string{"support"}}
+	users.Table.RowActions = []config.RowAction{
+		{
+			ID: "rename", Label: "Rename", Confirm: "Rename {{name}}?", Roles: []string{"admin"},
+			Params: []config.ActionParam{{ID: "name", Title: "Name", Required: true}},
+			SQL:    &config.ActionSQL{Statement: "UPDATE users SET name = :param.name WHERE id = :row.id"},
+		},
+		{
+			ID: "reset", Label: "Reset password",
+			Webhook: &config.ActionWebhook{URL: upstream.URL + "/users/{{row.id}}", Headers: map[string]string{"X-Secret": "s3"}},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	invalid := sampleConfig()
+	invalid.Pages[0].Widgets[0].Table.RowActions = []config.RowAction{{
+		ID: "close", Label: "Close", Webhook: &config.ActionWebhook{URL: upstream.URL + "/tickets/{{ param.ticket }}"},
+	}}
+	if err := invalid.Validate(); err == nil || !strings.Contains(err.Error(), "webhook url uses undeclared param ticket") {
+		t.Fatalf("expected undeclared webhook param to be rejected, got %v", err)
+	}
+
+	var out bytes.Buffer
+	sink := NewJSONLinesAuditSink(&out, 0)
+	app, err := New(cfg, providers.Registry{"db": sqlprovider.NewWithDB(db)},
+		WithAuthenticator(headerAuthenticator{}), WithAuditSink(sink),
+		WithTracer(tracing.NewTracer(tracing.NewInMemoryExporter())))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	run := func(action, roles, body string) (int, map[string]any) {
+		path, body, _ := strings.Cut(body, " ")
+		if body == "" {
+			path, body = "", path
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/widgets/users_table/actions/"+action+path, strings.NewReader(body))
+		req.Header.Set("X-Test-User", "ann")
+		req.Header.Set("X-Test-Roles", roles)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		var resp map[string]any
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	tests := []struct {
+		name   string
+		action string
+		roles  string
+		body   string
+		status int
+	}{
+		{"unknown action", "drop", "admin", `{}`, http.StatusNotFound},
+		{"missing role", "rename", "viewer", `{"key":1,"params":{"name":"Zed"},"confirmed":true}`, http.StatusForbidden},
+		{"unconfirmed", "rename", "admin", `{"key":1,"params":{"name":"Zed"}}`, http.StatusBadRequest},
+		{"missing param", "rename", "admin", `{"key":1,"confirmed":true}`, http.StatusBadRequest},
+		{"missing key", "rename", "admin", `{"params":{"name":"Zed"},"confirmed":true}`, http.StatusBadRequest},
+		{"key outside the widget", "rename", "admin", `{"key":3,"params":{"name":"Zed"},"confirmed":true}`, http.StatusNotFound},
+		{"key outside the filters", "rename", "admin", `?age.gt=40 {"key":1,"params":{"name":"Zed"},"confirmed":true}`, http.StatusNotFound},
+		{"malformed body", "rename", "admin", `{"key":`, http.StatusBadRequest},
+		{"webhook failure", "reset", "", `{"key":"1"}`, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if status, resp := run(tt.action, tt.roles, tt.body); status != tt.status {
+			t.Fatalf("%s: expected %d, got %d: %v", tt.name, tt.status, status, resp)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/widgets/users_table/actions/rename",
+		strings.NewReader(`{"key":1,"params":{"name":"Zed"},"confirmed":true}`))
+	req.Header.Set("X-Test-User", "cron")
+	req.Header.Set("X-Test-Roles", "admin")
+	req.Header.Set("X-Test-Scopes", "read")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a read-only token to be rejected, got %d", rec.Code)
+	}
+
+	status, resp := run("rename", "admin", `?age.gt=20 {"key":1,"params":{"name":"Zed"},"confirmed":true}`)
+	if status != http.StatusOK || resp["ok"] != true || resp["rows_affected"] != float64(1) {
+		t.Fatalf("unexpected rename response %d: %v", status, resp)
+	}
+	var name string
+	if err := db.Get(&name, "SELECT name FROM users WHERE id = 1"); err != nil || name != "Zed" {
+		t.Fatalf("expected renamed user, got %q (%v)", name, err)
+	}
+	if err := db.Get(&name, "SELECT name FROM users WHERE id = 3"); err != nil || name != "Bob" {
+		t.Fatalf("expected hidden user to be unchanged, got %q (%v)", name, err)
+	}
+
+	status, resp = run("reset", "", `{"key":2}`)
+	if status != http.StatusOK || resp["message"] != "reset sent" {
+		t.Fatalf("unexpected reset response %d: %v", status, resp)
+	}
+	if hook.path != "/users/2" || hook.secret != "s3" || hook.traceparent == "" {
+		t.Fatalf("unexpected webhook request %+v", hook)
+	}
+	if row := hook.payload.Row; hook.payload.Action != "reset" || hook.payload.User != "ann" ||
+		row["id"] != float64(2) || row["name"] != "Anna" || row["email"] != "***" {
+		t.Fatalf("expected the server-side masked row in the webhook payload, got %+v", hook.payload)
+	}
+
+	if err := app.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	var outcomes []string
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event struct {
+			Action  string              `json:"action"`
+			Filters map[string][]string `json:"filters"`
+			Rows    int                 `json:"rows"`
+			Outcome string              `json:"outcome"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("decode audit event: %v", err)
+		}
+		outcomes = append(outcomes, event.Action+" "+event.Outcome)
+		if event.Action == "action:rename" && event.Outcome == AuditSuccess &&
+			(event.Rows != 1 || !reflect.DeepEqual(event.Filters["param.name"], []string{"Zed"})) {
+			t.Fatalf("unexpected rename audit event %+v", event)
+		}
+	}
+	expected := []string{
+		"action:rename forbidden", "action:rename invalid_request", "action:rename invalid_request",
+		"action:rename invalid_request", "action:rename not_found", "action:rename not_found",
+		"action:rename invalid_request", "action:reset upstream_error", "action:rename forbidden",
+		"action:rename success", "action:reset success",
+	}
+	if !reflect.DeepEqual(outcomes, expected) {
+		t.Fatalf("unexpected audit outcomes %v", outcomes)
+	}
+}
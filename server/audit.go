@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const (
+	AuditView = "view"
+	// AuditActionPrefix is followed by the row action ID, e.g. "action:refund".
+	AuditActionPrefix = "action:"
+
+	AuditSuccess = "success"
+)
+
+// AuditEvent records one access to widget data or one row action.
+type AuditEvent struct {
+	Time     time.Time
+	User     string
+	Action   string
+	WidgetID string
+	// Filters maps "name" or "name.operator" to the requested values. For
+	// row actions it holds the bound "row.<column>" and "param.<id>" values.
+	Filters  map[string][]string
+	Search   string
+	Rows     int
+	Duration time.Duration
+	// Outcome is AuditSuccess or the error code of a failed request.
+	Outcome string
+}
+
+// AuditSink receives audit events. Audit is called on the request path and
+// must not block; see AsyncAuditSink.
+type AuditSink interface {
+	Audit(event AuditEvent)
+}
+
+// WithAuditSink sends audit events to sink. It can be given several times to
+// write to several sinks.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *Server) {
+		s.auditSinks = append(s.auditSinks, sink)
+	}
+}
+
+func (s *Server) audit(event AuditEvent) {
+	for _, sink := range s.auditSinks {
+		sink.Audit(event)
+	}
+}
+
+// auditFetch records a widget data access.
+func (s *Server) auditFetch(ctx context.Context, widget config.Widget, req providers.DataRequest, rows int,
+	duration time.Duration, err error) {
+	if len(s.auditSinks) == 0 {
+		return
+	}
+
+	outcome := AuditSuccess
+	if err != nil {
+		outcome = string(providers.AsError(err).Code)
+	}
+	id, _ := IdentityFromContext(ctx)
+	s.audit(AuditEvent{
+		Time:     time.Now().UTC(),
+		User:     id.Subject,
+		Action:   AuditView,
+		WidgetID: widget.ID,
+		Filters:  normalizeFilters(req.Filters),
+		Search:   req.Search,
+		Rows:     rows,
+		Duration: duration,
+		Outcome:  outcome,
+	})
+}
+
+// auditAction records a row action; rows is the number of affected rows.
+func (s *Server) auditAction(ctx context.Context, widget config.Widget, action config.RowAction,
+	bindings map[string]any, rows int, duration time.Duration, err error) {
+	if len(s.auditSinks) == 0 {
+		return
+	}
+
+	outcome := AuditSuccess
+	if err != nil {
+		outcome = string(providers.AsError(err).Code)
+	}
+	var values map[string][]string
+	if len(bindings) > 0 {
+		values = make(map[string][]string, len(bindings))
+		for name, value := range bindings {
+			if value != nil {
+				values[name] = []string{fmt.Sprint(value)}
+			}
+		}
+	}
+	id, _ := IdentityFromContext(ctx)
+	s.audit(AuditEvent{
+		Time:     time.Now().UTC(),
+		User:     id.Subject,
+		Action:   AuditActionPrefix + action.ID,
+		WidgetID: widget.ID,
+		Filters:  values,
+		Rows:     rows,
+		Duration: duration,
+		Outcome:  outcome,
+	})
+}
+
+func normalizeFilters(filters []providers.Filter) map[string][]string {
+	if len(filters) == 0 {
+		return nil
+	}
+	normalized := make(map[string][]string, len(filters))
+	for _, filter := range filters {
+		key := filter.Name
+		if filter.Operator != "" {
+			key += "." + string(filter.Operator)
+		}
+		normalized[key] = append(normalized[key], filter.Values...)
+	}
+	return normalized
+}
+
+// auditDropped sums the events dropped by all sinks that count them.
+func (s *Server) auditDropped() (uint64, bool) {
+	var total uint64
+	counted := false
+	for _, sink := range s.auditSinks {
+		if counter, ok := sink.(interface{ Dropped() uint64 }); ok {
+			total += counter.Dropped()
+			counted = true
+		}
+	}
+	return total, counted
+}
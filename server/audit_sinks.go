@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	DefaultAuditQueueSize = 1024
+	DefaultAuditTable     = "rapidmin_audit"
+
+	auditWriteTimeout = 5 * time.Second
+)
+
+// AsyncAuditSink queues events and writes them from a background goroutine.
+// When the queue is full, or writing fails, events are dropped and counted
+// instead of slowing requests down.
+type AsyncAuditSink struct {
+	write   func(AuditEvent) error
+	release func() error
+	queue   chan AuditEvent
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewAsyncAuditSink starts a sink calling write for every queued event.
+// release, if not nil, is called on Close after the queue is drained.
+func NewAsyncAuditSink(queueSize int, write func(AuditEvent) error, release func() error) *AsyncAuditSink {
+	if queueSize <= 0 {
+		queueSize = DefaultAuditQueueSize
+	}
+	s := &AsyncAuditSink{
+		write:   write,
+		release: release,
+		queue:   make(chan AuditEvent, queueSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *AsyncAuditSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.write(event); err != nil {
+			s.dropped.Add(1)
+		}
+	}
+}
+
+func (s *AsyncAuditSink) Audit(event AuditEvent) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many events were lost.
+func (s *AsyncAuditSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close writes the queued events and releases the underlying writer.
+func (s *AsyncAuditSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	<-s.done
+	if s.release != nil {
+		return s.release()
+	}
+	return nil
+}
+
+type auditRecord struct {
+	Time       string              `json:"time"`
+	User       string              `json:"user,omitempty"`
+	Action     string              `json:"action"`
+	WidgetID   string              `json:"widget_id"`
+	Filters    map[string][]string `json:"filters,omitempty"`
+	Search     string              `json:"search,omitempty"`
+	Rows       int                 `json:"rows"`
+	DurationMS float64             `json:"duration_ms"`
+	Outcome    string              `json:"outcome"`
+}
+
+func newAuditRecord(event AuditEvent) auditRecord {
+	return auditRecord{
+		Time:       event.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		User:       event.User,
+		Action:     event.Action,
+		WidgetID:   event.WidgetID,
+		Filters:    event.Filters,
+		Search:     event.Search,
+		Rows:       event.Rows,
+		DurationMS: float64(event.Duration.Microseconds()) / 1000,
+		Outcome:    event.Outcome,
+	}
+}
+
+// NewJSONLinesAuditSink writes one JSON object per event to w.
+func NewJSONLinesAuditSink(w io.Writer, queueSize int) *AsyncAuditSink {
+	encoder := json.NewEncoder(w)
+	return NewAsyncAuditSink(queueSize, func(event AuditEvent) error {
+		return encoder.Encode(newAuditRecord(event))
+	}, nil)
+}
+
+// NewFileAuditSink appends JSON lines to the file at path.
+func NewFileAuditSink(path string, queueSize int) (*AsyncAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+	return NewAsyncAuditSink(queueSize, func(event AuditEvent) error {
+		return encoder.Encode(newAuditRecord(event))
+	}, file.Close), nil
+}
+
+// NewSQLAuditSink inserts events into table, which must have the columns
+// occurred_at, user_id, action, widget_id, filters, search, row_count,
+// duration_ms and outcome. Filters are stored as JSON. The sink does not
+// close db.
+func NewSQLAuditSink(db *sqlx.DB, table string, queueSize int) *AsyncAuditSink {
+	if table == "" {
+		table = DefaultAuditTable
+	}
+	query := db.Rebind(`INSERT INTO ` + table + ` (occurred_at, user_id, action, widget_id, filters, search, row_count, duration_ms, outcome)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+
+	return NewAsyncAuditSink(queueSize, func(event AuditEvent) error {
+		filters, err := json.Marshal(event.Filters)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+		defer cancel()
+		_, err = db.ExecContext(ctx, query, event.Time, event.User, event.Action, event.WidgetID, string(filters),
+			event.Search, event.Rows, event.Duration.Milliseconds(), event.Outcome)
+		return err
+	}, nil)
+}
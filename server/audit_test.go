@@ -0,0 +1,102 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAsyncAuditSinkDropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	written := 0
+	sink := NewAsyncAuditSink(1, func(AuditEvent) error {
+		<-release
+		written++
+		return nil
+	}, nil)
+
+	// The first event is taken by the writer, the second fills the queue.
+	sink.Audit(AuditEvent{WidgetID: "a"})
+	deadline := time.Now().Add(time.Second)
+	for len(sink.queue) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	sink.Audit(AuditEvent{WidgetID: "b"})
+	sink.Audit(AuditEvent{WidgetID: "c"})
+	if sink.Dropped() != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", sink.Dropped())
+	}
+
+	close(release)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if written != 2 {
+		t.Fatalf("expected 2 written events, got %d", written)
+	}
+
+	sink.Audit(AuditEvent{WidgetID: "d"})
+	if sink.Dropped() != 2 {
+		t.Fatalf("expected events after close to be dropped, got %d", sink.Dropped())
+	}
+}
+
+func TestAsyncAuditSinkCountsWriteFailures(t *testing.T) {
+	sink := NewAsyncAuditSink(4, func(AuditEvent) error { return errors.New("disk full") }, nil)
+	sink.Audit(AuditEvent{})
+	sink.Audit(AuditEvent{})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if sink.Dropped() != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", sink.Dropped())
+	}
+}
+
+func TestSQLAuditSink(t *testing.T) {
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec(`CREATE TABLE rapidmin_audit (
+		occurred_at TIMESTAMP, user_id TEXT, action TEXT, widget_id TEXT, filters TEXT,
+		search TEXT, row_count INTEGER, duration_ms INTEGER, outcome TEXT)`); err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	sink := NewSQLAuditSink(db, "", 0)
+	sink.Audit(AuditEvent{
+		Time:     time.Now().UTC(),
+		User:     "ann",
+		Action:   AuditView,
+		WidgetID: "users_table",
+		Filters:  map[string][]string{"age.gt": {"40"}},
+		Rows:     3,
+		Duration: 1500 * time.Millisecond,
+		Outcome:  AuditSuccess,
+	})
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	var row struct {
+		User     string `db:"user_id"`
+		Widget   string `db:"widget_id"`
+		Filters  string `db:"filters"`
+		Rows     int    `db:"row_count"`
+		Duration int    `db:"duration_ms"`
+		Outcome  string `db:"outcome"`
+	}
+	if err := db.Get(&row, `SELECT user_id, widget_id, filters, row_count, duration_ms, outcome FROM rapidmin_audit`); err != nil {
+		t.Fatalf("select audit row: %v", err)
+	}
+	if row.User != "ann" || row.Widget != "users_table" || row.Filters != `{"age.gt":["40"]}` ||
+		row.Rows != 3 || row.Duration != 1500 || row.Outcome != AuditSuccess {
+		t.Fatalf("unexpected audit row %+v", row)
+	}
+}
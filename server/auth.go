@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Authenticator identifies the user of a request. Authenticators that also
+// implement http.Handler serve their own endpoints, such as login and
+// callback, below <path_prefix>/auth/.
+type Authenticator interface {
+	// Authenticate returns the identity of the request; ok is false for
+	// anonymous requests.
+	Authenticate(r *http.Request) (id Identity, ok bool)
+}
+
+// WithAuthenticator identifies users with a. It can be given several times;
+// the first authenticator recognising a request wins. Once an authenticator
+// is set, anonymous requests to the API are rejected.
+func WithAuthenticator(a Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, a)
+	}
+}
+
+// authHandler returns the authenticator serving /auth/ endpoints, if any.
+func (s *Server) authHandler() http.Handler {
+	for _, a := range s.authenticators {
+		if handler, ok := a.(http.Handler); ok {
+			return handler
+		}
+	}
+	return nil
+}
+
+// authenticate attaches the identity of the request to its context. When
+// authentication is configured, anonymous API requests get 401 and the UI
+// redirects to the login endpoint. Probes, metrics and /auth/ stay public.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if len(s.authenticators) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, a := range s.authenticators {
+			if id, ok := a.Authenticate(r); ok {
+				next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), id)))
+				return
+			}
+		}
+
+		switch path := r.URL.Path; {
+		case r.Method == http.MethodOptions, path == "/healthz", path == "/readyz", path == "/metrics",
+			path == "/auth" || strings.HasPrefix(path, "/auth/"):
+			next.ServeHTTP(w, r)
+		case path == "/api" || strings.HasPrefix(path, "/api/"):
+			s.writeError(w, errUnauthorized)
+		case r.Method == http.MethodGet && s.authHandler() != nil:
+			target := s.pathPrefix + "/auth/login?return_to=" + url.QueryEscape(s.pathPrefix+r.URL.RequestURI())
+			http.Redirect(w, r, target, http.StatusFound)
+		default:
+			s.writeError(w, errUnauthorized)
+		}
+	})
+}
+
+// handleAuth forwards /auth/ requests to the authenticator that serves them.
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	handler := s.authHandler()
+	if handler == nil {
+		s.writeError(w, errNotFound)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// handleMe returns the identity of the current user.
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	id, ok := IdentityFromContext(r.Context())
+	if !ok {
+		s.writeError(w, errUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(id)
+}
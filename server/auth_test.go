@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/providers/cache"
+)
+
+// headerAuthenticator trusts the X-Test-User header; X-Test-Widgets limits
+// the identity to a comma-separated list of widgets, X-Test-Roles lists its
+// roles and X-Test-Scopes its scopes.
+type headerAuthenticator struct{}
+
+func (headerAuthenticator) Authenticate(r *http.Request) (Identity, bool) {
+	user := r.Header.Get("X-Test-User")
+	if user == "" {
+		return Identity{}, false
+	}
+	id := Identity{Subject: user}
+	if widgets := r.Header.Get("X-Test-Widgets"); widgets != "" {
+		id.Widgets = strings.Split(widgets, ",")
+	}
+	if roles := r.Header.Get("X-Test-Roles"); roles != "" {
+		id.Roles = strings.Split(roles, ",")
+	}
+	if scopes := r.Header.Get("X-Test-Scopes"); scopes != "" {
+		id.Scopes = strings.Split(scopes, ",")
+	}
+	return id, true
+}
+
+type staticProvider struct{}
+
+func (staticProvider) Init(context.Context, string, config.ProviderConfig) error {
+	return nil
+}
+
+func (staticProvider) Fetch(context.Context, config.Widget, providers.DataRequest) (providers.DataResponse, error) {
+	return providers.DataResponse{Data: []map[string]any{{"id": 1}}}, nil
+}
+
+func TestAuthentication(t *testing.T) {
+	cfg := config.AppConfig{Pages: []config.Page{{Slug: "ops", Widgets: []config.Widget{
+		{ID: "orders", Type: config.TableWidget, Provider: config.ProviderSpec{Name: "db"}},
+		{ID: "users", Type: config.TableWidget, Provider: config.ProviderSpec{Name: "db"}},
+	}}}}
+	srv, err := New(cfg, providers.Registry{"db": staticProvider{}}, WithAuthenticator(headerAuthenticator{}))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	scoped := map[string]string{"X-Test-User": "cron", "X-Test-Widgets": "orders"}
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"anonymous api", http.MethodGet, "/api/widgets/orders", nil, http.StatusUnauthorized},
+		{"anonymous me", http.MethodGet, "/api/me", nil, http.StatusUnauthorized},
+		{"anonymous page without login", http.MethodGet, "/ops", nil, http.StatusUnauthorized},
+		{"anonymous probe", http.MethodGet, "/healthz", nil, http.StatusOK},
+		{"authenticated", http.MethodGet, "/api/widgets/users", map[string]string{"X-Test-User": "ann"}, http.StatusOK},
+		{"scoped allowed", http.MethodGet, "/api/widgets/orders", scoped, http.StatusOK},
+		{"scoped denied", http.MethodGet, "/api/widgets/users", scoped, http.StatusForbidden},
+		{"scoped purge denied", http.MethodDelete, "/api/admin/cache/users", scoped, http.StatusForbidden},
+		{"purge without admin role", http.MethodDelete, "/api/admin/cache/users", map[string]string{"X-Test-User": "ann"}, http.StatusForbidden},
+		{"admin purge", http.MethodDelete, "/api/admin/cache/users", map[string]string{"X-Test-User": "ann", "X-Test-Roles": "admin"}, http.StatusNoContent},
+		{"admin purge with write scope", http.MethodDelete, "/api/admin/cache/users", map[string]string{"X-Test-User": "ann", "X-Test-Roles": "admin", "X-Test-Scopes": "read,write"}, http.StatusForbidden},
+		{"admin purge with admin scope", http.MethodDelete, "/api/admin/cache/users", map[string]string{"X-Test-User": "ann", "X-Test-Roles": "admin", "X-Test-Scopes": "admin"}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if rec := serve(tt.method, tt.path, tt.headers); rec.Code != tt.status {
+			t.Fatalf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+
+	anonymous, err := New(cfg, providers.Registry{"db": staticProvider{}})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	anonymous.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/admin/cache/users", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected purge without authentication to be forbidden, got %d", rec.Code)
+	}
+
+	rec = serve(http.MethodGet, "/api/me", scoped)
+	var id Identity
+	if err := json.NewDecoder(rec.Body).Decode(&id); err != nil || id.Subject != "cron" || len(id.Widgets) != 1 {
+		t.Fatalf("unexpected identity %+v (%v)", id, err)
+	}
+
+	rec = serve(http.MethodGet, "/api/pages/ops/data", scoped)
+	var results map[string]widgetResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("decode page data: %v", err)
+	}
+	if results["orders"].Error != nil || results["users"].Error == nil || results["users"].Error.Code != providers.ForbiddenError {
+		t.Fatalf("expected only users to be forbidden, got %+v", results)
+	}
+}
+
+type countingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Init(context.Context, string, config.ProviderConfig) error {
+	return nil
+}
+
+func (p *countingProvider) Fetch(context.Context, config.Widget, providers.DataRequest) (providers.DataResponse, error) {
+	return providers.DataResponse{Data: []map[string]any{{"call": p.calls.Add(1)}}}, nil
+}
+
+func TestCacheScopedPerUser(t *testing.T) {
+	cfg := config.AppConfig{Pages: []config.Page{{Slug: "ops", Widgets: []config.Widget{
+		{ID: "orders", Type: config.TableWidget, Provider: config.ProviderSpec{Name: "db"}, Cache: &config.CacheSpec{TTL: time.Minute}},
+	}}}}
+	next := &countingProvider{}
+	srv, err := New(cfg, providers.Registry{"db": cache.New(next)}, WithAuthenticator(headerAuthenticator{}))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	for _, user := range []string{"ann", "ann", "bob"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/widgets/orders", nil)
+		req.Header.Set("X-Test-User", user)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", user, rec.Code)
+		}
+	}
+	if calls := next.calls.Load(); calls != 2 {
+		t.Fatalf("expected one fetch per user, got %d", calls)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func chartResponse(spec config.ChartSpec, data providers.DataResponse) providers.ChartResponse {
+	resp := providers.ChartResponse{
+		Labels: make([]any, 0, len(data.Data)),
+		Series: make([]providers.ChartSeries, 0, len(spec.Measures)),
+	}
+	for _, measure := range spec.Measures {
+		title := measure.Title
+		if title == "" {
+			title = measure.Key()
+		}
+		resp.Series = append(resp.Series, providers.ChartSeries{
+			ID:    measure.Key(),
+			Title: title,
+			Data:  make([]any, 0, len(data.Data)),
+		})
+	}
+
+	for _, row := range data.Data {
+		resp.Labels = append(resp.Labels, row[spec.Dimension])
+		for i := range resp.Series {
+			resp.Series[i].Data = append(resp.Series[i].Data, row[resp.Series[i].ID])
+		}
+	}
+
+	return resp
+}
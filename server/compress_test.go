@@ -0,0 +1,20 @@
+package server
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"gzip, deflate, br":   "br",
+		"gzip;q=1, br;q=0":    "gzip",
+		"identity":            "",
+		"*":                   "br",
+		"*, br;q=0":           "gzip",
+		"deflate, GZIP;q=0.5": "gzip",
+	}
+	for header, expected := range tests {
+		if got := negotiateEncoding(header); got != expected {
+			t.Fatalf("negotiateEncoding(%q) = %q, expected %q", header, got, expected)
+		}
+	}
+}
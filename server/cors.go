@@ -0,0 +1,145 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodHead}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "If-None-Match"}
+	defaultCORSExposed = []string{"ETag", "Retry-After"}
+)
+
+// corsPolicy is the resolved CORS configuration. Header names are kept in
+// canonical form.
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	methods     []string
+	headers     map[string]bool
+	allowHeader string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+func newCORSPolicy(cfg *config.CORSConfig) *corsPolicy {
+	if cfg == nil || len(cfg.AllowedOrigins) == 0 {
+		return nil
+	}
+
+	policy := &corsPolicy{
+		origins:     map[string]bool{},
+		headers:     map[string]bool{},
+		credentials: cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			policy.anyOrigin = true
+			continue
+		}
+		policy.origins[normalizeOrigin(origin)] = true
+	}
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	for _, method := range methods {
+		policy.methods = append(policy.methods, strings.ToUpper(method))
+	}
+
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	canonical := make([]string, 0, len(headers))
+	for _, header := range headers {
+		header = http.CanonicalHeaderKey(header)
+		policy.headers[header] = true
+		canonical = append(canonical, header)
+	}
+	policy.allowHeader = strings.Join(canonical, ", ")
+
+	exposed := cfg.ExposedHeaders
+	if len(exposed) == 0 {
+		exposed = defaultCORSExposed
+	}
+	policy.exposed = strings.Join(exposed, ", ")
+
+	if cfg.MaxAge > 0 {
+		policy.maxAge = strconv.FormatInt(int64(cfg.MaxAge.Seconds()), 10)
+	}
+	return policy
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	return p.anyOrigin || p.origins[normalizeOrigin(origin)]
+}
+
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !p.headers[http.CanonicalHeaderKey(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// cors adds CORS headers for allowed origins. Preflight requests get the
+// allowed methods and headers here; the router answers them with 204.
+func (s *Server) cors(next http.Handler) http.Handler {
+	if s.corsPolicy == nil {
+		return next
+	}
+	policy := s.corsPolicy
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || !policy.allowsOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestedMethod != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			if !slices.Contains(policy.methods, requestedMethod) ||
+				!policy.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			header.Set("Access-Control-Allow-Methods", strings.Join(policy.methods, ", "))
+			header.Set("Access-Control-Allow-Headers", policy.allowHeader)
+			if policy.maxAge != "" {
+				header.Set("Access-Control-Max-Age", policy.maxAge)
+			}
+		} else if policy.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", policy.exposed)
+		}
+
+		// Origins allowed only by "*" never get credentials, so that no
+		// website can read authenticated responses.
+		if !policy.origins[normalizeOrigin(origin)] {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if policy.credentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
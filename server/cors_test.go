@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := config.AppConfig{CORS: &config.CORSConfig{
+		AllowedOrigins:   []string{"https://tools.example.com"},
+		AllowedMethods:   []string{"get", "delete"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}}
+	srv, err := New(cfg, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodOptions, "/api/widgets/users", map[string]string{
+		"Origin":                         "https://tools.example.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "authorization",
+	})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected preflight 204, got %d", rec.Code)
+	}
+	for key, expected := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://tools.example.com",
+		"Access-Control-Allow-Methods":     "GET, DELETE",
+		"Access-Control-Allow-Headers":     "Authorization, Content-Type, If-None-Match",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := rec.Header().Get(key); got != expected {
+			t.Fatalf("preflight %s = %q, expected %q", key, got, expected)
+		}
+	}
+
+	for name, headers := range map[string]map[string]string{
+		"foreign origin": {"Origin": "https://evil.example", "Access-Control-Request-Method": "GET"},
+		"method":         {"Origin": "https://tools.example.com", "Access-Control-Request-Method": "POST"},
+		"header": {
+			"Origin":                         "https://tools.example.com",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "X-Secret",
+		},
+	} {
+		rec := serve(http.MethodOptions, "/api/widgets/users", headers)
+		if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("%s: expected preflight to be refused", name)
+		}
+	}
+
+	rec = serve(http.MethodGet, "/api/widgets/users", map[string]string{"Origin": "https://tools.example.com"})
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://tools.example.com" ||
+		rec.Header().Get("Access-Control-Expose-Headers") != "ETag, Retry-After" ||
+		!slices.Contains(rec.Header().Values("Vary"), "Origin") {
+		t.Fatalf("unexpected CORS headers %v", rec.Header())
+	}
+
+	// Origins allowed by CORS are not trusted for state-changing requests.
+	rec = serve(http.MethodPost, "/api/widgets/users/actions/archive", map[string]string{
+		"Origin":         "https://tools.example.com",
+		"Sec-Fetch-Site": "cross-site",
+	})
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected the CSRF check to reject a CORS origin, got %d", rec.Code)
+	}
+
+	cfg.Security = &config.SecurityConfig{TrustedOrigins: []string{"https://tools.example.com"}}
+	srv, err = New(cfg, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	rec = serve(http.MethodPost, "/api/widgets/users/actions/archive", map[string]string{
+		"Origin":         "https://tools.example.com",
+		"Sec-Fetch-Site": "cross-site",
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected a trusted origin to reach the handler, got %d", rec.Code)
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	srv, err := New(config.AppConfig{CORS: &config.CORSConfig{AllowedOrigins: []string{"*"}}}, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+	req.Header.Set("Origin", "https://any.example")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatalf("unexpected CORS headers %v", rec.Header())
+	}
+}
+
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	cfg := config.AppConfig{CORS: &config.CORSConfig{
+		AllowedOrigins:   []string{"https://tools.example.com", "*"},
+		AllowCredentials: true,
+	}}
+	if err := cfg.Validate(); err == nil {
+		t.Fatalf("expected wildcard origin with credentials to be rejected")
+	}
+	srv, err := New(cfg, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	for origin, allowed := range map[string]string{"https://evil.example": "*", "https://tools.example.com": "https://tools.example.com"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/config", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		credentials := rec.Header().Get("Access-Control-Allow-Credentials") == "true"
+		if rec.Header().Get("Access-Control-Allow-Origin") != allowed || credentials != (allowed != "*") {
+			t.Fatalf("%s: unexpected CORS headers %v", origin, rec.Header())
+		}
+	}
+}
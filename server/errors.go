@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+var (
+	errUnknownProvider  = providers.NewError(providers.ConfigError, "unknown provider")
+	errNotFound         = providers.NewError(providers.NotFoundError, "not found")
+	errCrossOrigin      = providers.NewError(providers.ForbiddenError, "cross-origin request rejected")
+	errUnauthorized     = providers.NewError(providers.UnauthorizedError, "authentication required")
+	errWidgetForbidden  = providers.NewError(providers.ForbiddenError, "widget not permitted")
+	errAdminRequired    = providers.NewError(providers.ForbiddenError, "admin role required")
+	errWriteScope       = providers.NewError(providers.ForbiddenError, "token scope write required")
+	errAdminScope       = providers.NewError(providers.ForbiddenError, "token scope admin required")
+	errMethodNotAllowed = providers.NewError(providers.MethodError, "method not allowed")
+	errRateLimited      = providers.NewError(providers.RateLimitedError, "rate limit exceeded, retry later")
+	errProviderBusy     = providers.NewError(providers.RateLimitedError, "too many concurrent requests, retry later")
+)
+
+type errorResponse struct {
+	Error errorPayload `json:"error"`
+}
+
+type errorPayload struct {
+	Code    providers.ErrorCode `json:"code"`
+	Message string              `json:"message"`
+	Field   string              `json:"field,omitempty"`
+	// RetryAfter is set on rate limited widgets of page data requests,
+	// which cannot use the Retry-After header.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+func newErrorPayload(err error) errorPayload {
+	typed := providers.AsError(err)
+	return errorPayload{Code: typed.Code, Message: typed.Message, Field: typed.Field}
+}
+
+// writeError writes err as a JSON error body. Only the user-facing message
+// is sent; internal details stay on the server.
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	typed := providers.AsError(err)
+	s.metrics.observeError(string(typed.Code))
+	s.logError(typed)
+
+	status := typed.Status
+	if status == 0 {
+		status = typed.Code.Status()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorResponse{Error: newErrorPayload(typed)})
+}
+
+// logError logs server-side failures with their internal detail, which is
+// never sent to clients.
+func (s *Server) logError(err *providers.Error) {
+	level := slog.LevelDebug
+	if err.Code.Status() >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	s.logger.LogAttrs(context.Background(), level, "request failed",
+		slog.String("code", string(err.Code)),
+		slog.String("message", err.Message),
+		slog.String("detail", err.Detail),
+	)
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const (
+	defaultValuesLimit    = 100
+	defaultValuesCacheTTL = time.Minute
+	maxValuesCacheEntries = 1024
+)
+
+type filterValuesResponse struct {
+	Values  []config.ValueOption `json:"values"`
+	HasMore bool                 `json:"has_more,omitempty"`
+}
+
+func (s *Server) handleFilterValues(w http.ResponseWriter, r *http.Request) {
+	widget, ok := s.findWidget(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, errNotFound)
+		return
+	}
+
+	if !widgetAllowed(r.Context(), widget) {
+		s.writeError(w, errWidgetForbidden)
+		return
+	}
+
+	filter, ok := findFilter(widget.FilterSpecs(), r.PathValue("filter"))
+	if !ok || filter.ValuesFrom == nil {
+		s.writeError(w, errNotFound)
+		return
+	}
+
+	s.serveFilterValues(w, r, valuesWidget(widget.ID, widget.RateLimit, filter), *filter.ValuesFrom)
+}
+
+// handlePageFilterValues serves the values_from options of a page filter to
+// users who can see at least one widget of the page.
+func (s *Server) handlePageFilterValues(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.findPage(r.PathValue("slug"))
+	if !ok {
+		s.writeError(w, errNotFound)
+		return
+	}
+
+	if !slices.ContainsFunc(page.Widgets, func(widget config.Widget) bool {
+		return widgetAllowed(r.Context(), widget)
+	}) {
+		s.writeError(w, errWidgetForbidden)
+		return
+	}
+
+	filter, ok := findFilter(page.Filters, r.PathValue("filter"))
+	if !ok || filter.ValuesFrom == nil {
+		s.writeError(w, errNotFound)
+		return
+	}
+
+	s.serveFilterValues(w, r, valuesWidget("page:"+page.Slug, nil, filter), *filter.ValuesFrom)
+}
+
+// serveFilterValues loads filter value options through the same rate limits,
+// in-flight caps, tracing, metrics and audit as widget data. Responses are
+// cached per search term.
+func (s *Server) serveFilterValues(w http.ResponseWriter, r *http.Request, widget config.Widget,
+	source config.ValuesSourceSpec) {
+	provider, ok := s.providers.Get(widget.Provider.Name)
+	if !ok {
+		s.writeError(w, errUnknownProvider)
+		return
+	}
+
+	release, wait, err := s.acquireWidget(r, widget, false)
+	if err != nil {
+		writeRetryAfter(w, wait)
+		s.writeError(w, err)
+		return
+	}
+	defer release()
+
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	cacheKey := widget.ID + "|" + search
+	if cached, ok := s.valuesCache.get(cacheKey); ok {
+		writeFilterValues(w, cached)
+		return
+	}
+
+	limit := source.Limit
+	if limit <= 0 {
+		limit = defaultValuesLimit
+	}
+
+	data, err := s.fetchData(r.Context(), provider, widget, providers.DataRequest{
+		Limit:  limit,
+		Search: search,
+	})
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := filterValuesResponse{
+		Values:  make([]config.ValueOption, 0, len(data.Data)),
+		HasMore: data.HasMore,
+	}
+	for _, row := range data.Data {
+		resp.Values = append(resp.Values, valueOption(row))
+	}
+
+	ttl := source.CacheTTL
+	if ttl == 0 {
+		ttl = defaultValuesCacheTTL
+	}
+	if ttl > 0 {
+		s.valuesCache.set(cacheKey, resp, ttl)
+	}
+
+	writeFilterValues(w, resp)
+}
+
+// valuesWidget describes the values_from query of a filter owned by a widget
+// or page as a widget so that it can be fetched through the regular provider
+// interface, searching over labels. It uses the owner's rate_limit setting.
+func valuesWidget(owner string, rateLimit *config.RateSpec, filter config.FilterSpec) config.Widget {
+	return config.Widget{
+		ID:        owner + "." + filter.ID,
+		Type:      "values",
+		Provider:  filter.ValuesFrom.Provider,
+		RateLimit: rateLimit,
+		Table: &config.TableSpec{
+			Search: &config.SearchSpec{Columns: []string{"label"}},
+		},
+	}
+}
+
+func valueOption(row map[string]any) config.ValueOption {
+	option := config.ValueOption{
+		Value: stringValue(row["value"]),
+		Label: stringValue(row["label"]),
+	}
+	if option.Label == "" {
+		option.Label = option.Value
+	}
+	return option
+}
+
+func stringValue(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func writeFilterValues(w http.ResponseWriter, resp filterValuesResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func findFilter(filters []config.FilterSpec, id string) (config.FilterSpec, bool) {
+	for _, filter := range filters {
+		if filter.ID == id {
+			return filter, true
+		}
+	}
+	return config.FilterSpec{}, false
+}
+
+type valuesCacheEntry struct {
+	resp      filterValuesResponse
+	expiresAt time.Time
+}
+
+// valuesCache keeps filter value responses in memory until their TTL expires.
+// It is bounded: once full, expired entries are evicted and, if that is not
+// enough, the whole cache is dropped.
+type valuesCache struct {
+	mu      sync.Mutex
+	entries map[string]valuesCacheEntry
+}
+
+func (c *valuesCache) get(key string) (filterValuesResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return filterValuesResponse{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return filterValuesResponse{}, false
+	}
+	return entry.resp, true
+}
+
+func (c *valuesCache) set(key string, resp filterValuesResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]valuesCacheEntry{}
+	}
+	if len(c.entries) >= maxValuesCacheEntries {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxValuesCacheEntries {
+			c.entries = map[string]valuesCacheEntry{}
+		}
+	}
+	c.entries[key] = valuesCacheEntry{resp: resp, expiresAt: time.Now().Add(ttl)}
+}
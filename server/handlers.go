@@ -1,91 +1,185 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ankulikov/rapidmin/config"
 	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/tracing"
 )
 
 const defaultLimit = 50
 
 func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	body, etag, err := s.renderConfig()
+	if err != nil {
+		s.writeError(w, err)
 		return
 	}
+	writeCached(w, r, "application/json", etag, body)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(s.cfg)
+func (s *Server) renderConfig() ([]byte, string, error) {
+	s.configOnce.Do(func() {
+		s.configJSON, s.configErr = json.Marshal(s.cfg)
+		s.configETag = contentETag(s.configJSON)
+	})
+	return s.configJSON, s.configETag, s.configErr
 }
 
 func (s *Server) handleWidgetData(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	widget, ok := s.findWidget(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, errNotFound)
 		return
 	}
 
-	widgetID, ok := s.parseWidgetID(r.URL.Path)
-	if !ok {
-		http.NotFound(w, r)
+	payload, err := s.fetchWidget(r.Context(), widget, r.URL.Query())
+	if err != nil {
+		s.writeError(w, err)
 		return
 	}
 
-	widget, ok := s.findWidget(widgetID)
+	if widget.ETag {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			s.writeError(w, err)
+			return
+		}
+		writeCached(w, r, "application/json", contentETag(body), body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// handlePurgeCache drops every cached response of a widget. It requires an
+// admin role and, for API tokens, the admin scope.
+func (s *Server) handlePurgeCache(w http.ResponseWriter, r *http.Request) {
+	if !s.adminAllowed(r.Context()) {
+		s.writeError(w, errAdminRequired)
+		return
+	}
+	if id, _ := IdentityFromContext(r.Context()); !id.HasScope(AdminScope) {
+		s.writeError(w, errAdminScope)
+		return
+	}
+	widget, ok := s.findWidget(r.PathValue("id"))
 	if !ok {
-		http.NotFound(w, r)
+		s.writeError(w, errNotFound)
+		return
+	}
+	if !widgetAllowed(r.Context(), widget) {
+		s.writeError(w, errWidgetForbidden)
 		return
 	}
 
 	provider, ok := s.providers.Get(widget.Provider.Name)
 	if !ok {
-		http.Error(w, "unknown provider", http.StatusBadRequest)
+		s.writeError(w, errUnknownProvider)
 		return
 	}
 
-	req := providers.DataRequest{
-		Limit:   parseInt(r.URL.Query().Get("limit"), defaultLimit),
-		Cursor:  r.URL.Query().Get("offset"),
-		Filters: parseFilters(r.URL.Query()),
+	if purger, ok := provider.(providers.Purger); ok {
+		purger.Purge(widget.ID)
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	data, err := provider.Fetch(r.Context(), widget, req)
+// fetchWidget loads widget data for the request query and shapes it into the
+// response of the widget type.
+func (s *Server) fetchWidget(ctx context.Context, widget config.Widget, query url.Values) (any, error) {
+	provider, ok := s.providers.Get(widget.Provider.Name)
+	if !ok {
+		return nil, errUnknownProvider
+	}
+
+	req := s.dataRequest(ctx, widget, query)
+	switch widget.Type {
+	case config.StatWidget:
+		req.Limit = 1
+		req.Cursor = ""
+	case config.ChartWidget:
+		req.Limit = 0
+		req.Cursor = ""
+	}
+
+	if !widgetAllowed(ctx, widget) {
+		s.auditFetch(ctx, widget, req, 0, 0, errWidgetForbidden)
+		return nil, errWidgetForbidden
+	}
+
+	data, err := s.fetchData(ctx, provider, widget, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotImplemented)
-		return
+		return nil, err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(data)
+	return widgetPayload(widget, data), nil
 }
 
-func (s *Server) parseWidgetID(path string) (string, bool) {
-	prefix := s.apiWidgetsPrefix()
-	if !strings.HasPrefix(path, prefix) {
-		return "", false
+// fetchData runs a provider query of widget, tracing, measuring and auditing
+// it.
+func (s *Server) fetchData(ctx context.Context, provider providers.Provider, widget config.Widget,
+	req providers.DataRequest) (providers.DataResponse, error) {
+	ctx, span := tracing.Start(ctx, "provider.fetch",
+		tracing.String("widget.id", widget.ID),
+		tracing.String("provider.name", widget.Provider.Name),
+	)
+	start := time.Now()
+	data, err := provider.Fetch(ctx, widget, req)
+	duration := time.Since(start)
+	s.metrics.observeQuery(widget.Provider.Name, widget.ID, len(data.Data), duration)
+	s.auditFetch(ctx, widget, req, len(data.Data), duration, err)
+	span.SetAttributes(tracing.Int("rows", len(data.Data)))
+	span.RecordError(err)
+	span.End()
+	if info := requestInfoFrom(ctx); info != nil {
+		info.rows = len(data.Data)
 	}
+	return data, err
+}
 
-	trimmed := strings.TrimPrefix(path, prefix)
-	trimmed = strings.Trim(trimmed, "/")
-	if trimmed == "" {
-		return "", false
+// dataRequest builds the provider request of widget from the query params
+// and the caller's identity.
+func (s *Server) dataRequest(ctx context.Context, widget config.Widget, query url.Values) providers.DataRequest {
+	req := providers.DataRequest{
+		Limit:       parseInt(query.Get("limit"), defaultLimit),
+		Cursor:      query.Get("offset"),
+		Search:      query.Get("q"),
+		Filters:     parseFilters(query),
+		PageFilters: s.pageFilterSpecs(widget),
 	}
-	if strings.Contains(trimmed, "/") {
-		return "", false
+	// Cached responses are only shared by requests of the same user.
+	if id, ok := IdentityFromContext(ctx); ok {
+		req.Scope = "user:" + id.Subject
+		req.Roles = id.Roles
 	}
+	return req
+}
 
-	return trimmed, true
+// widgetPayload shapes provider data into the response of the widget type.
+func widgetPayload(widget config.Widget, data providers.DataResponse) any {
+	switch {
+	case widget.Type == config.StatWidget && widget.Stat != nil:
+		return statResponse(*widget.Stat, data)
+	case widget.Type == config.ChartWidget && widget.Chart != nil:
+		return chartResponse(*widget.Chart, data)
+	}
+	return data
 }
 
 func parseFilters(values url.Values) []providers.Filter {
 	filtersByKey := map[string]*providers.Filter{}
 	order := []string{}
 	for key, values := range values {
-		if key == "limit" || key == "offset" {
+		if key == "limit" || key == "offset" || key == "q" {
 			continue
 		}
 		if len(values) == 0 {
@@ -141,6 +235,24 @@ func parseInt(value string, fallback int) int {
 	return parsed
 }
 
+// adminAllowed reports whether the caller has an admin role. Anonymous
+// callers never do, so admin endpoints are closed without authentication.
+func (s *Server) adminAllowed(ctx context.Context) bool {
+	roles := []string{"admin"}
+	if s.cfg.Auth != nil && len(s.cfg.Auth.AdminRoles) > 0 {
+		roles = s.cfg.Auth.AdminRoles
+	}
+	id, ok := IdentityFromContext(ctx)
+	return ok && id.HasRole(roles...)
+}
+
+// widgetAllowed reports whether the user of ctx may access widget. Requests
+// without an identity are allowed; authentication is enforced earlier.
+func widgetAllowed(ctx context.Context, widget config.Widget) bool {
+	id, ok := IdentityFromContext(ctx)
+	return !ok || id.CanAccessWidget(widget.ID)
+}
+
 func (s *Server) findWidget(id string) (config.Widget, bool) {
 	for _, page := range s.cfg.Pages {
 		for _, widget := range page.Widgets {
@@ -152,3 +264,39 @@ func (s *Server) findWidget(id string) (config.Widget, bool) {
 
 	return config.Widget{}, false
 }
+
+// pageFilterSpecs resolves the filters of the page holding the widget to the
+// widget's own targets.
+func (s *Server) pageFilterSpecs(widget config.Widget) []config.FilterSpec {
+	page, ok := s.findWidgetPage(widget.ID)
+	if !ok || len(page.Filters) == 0 {
+		return nil
+	}
+
+	specs := make([]config.FilterSpec, 0, len(page.Filters))
+	for _, filter := range page.Filters {
+		target, ok := widget.PageFilters[filter.ID]
+		if !ok {
+			target = filter.Target
+		}
+		if target == "" {
+			continue
+		}
+		filter.Target = target
+		specs = append(specs, filter)
+	}
+
+	return specs
+}
+
+func (s *Server) findWidgetPage(id string) (config.Page, bool) {
+	for _, page := range s.cfg.Pages {
+		for _, widget := range page.Widgets {
+			if widget.ID == id {
+				return page, true
+			}
+		}
+	}
+
+	return config.Page{}, false
+}
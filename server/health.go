@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const readyTimeout = 2 * time.Second
+
+type readyResponse struct {
+	Status    string            `json:"status"`
+	Providers map[string]string `json:"providers,omitempty"`
+}
+
+// handleHealthz reports that the process is alive.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// handleReadyz pings every provider that supports health checks and reports
+// 503 when any of them is unreachable.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	resp := readyResponse{Status: "ok", Providers: map[string]string{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, provider := range s.providers {
+		checker, ok := provider.(providers.HealthChecker)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, checker providers.HealthChecker) {
+			defer wg.Done()
+			status := "ok"
+			if err := checker.Ping(ctx); err != nil {
+				status = "unavailable"
+			}
+			mu.Lock()
+			resp.Providers[name] = status
+			if status != "ok" {
+				resp.Status = "unavailable"
+			}
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"slices"
+)
+
+// Scopes of API tokens. Each scope includes the ones before it.
+const (
+	ReadScope  = "read"
+	WriteScope = "write"
+	AdminScope = "admin"
+)
+
+var scopeOrder = []string{ReadScope, WriteScope, AdminScope}
+
+// Identity is the authenticated user of a request.
+type Identity struct {
+	Subject    string            `json:"subject"`
+	Roles      []string          `json:"roles,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Widgets restricts the identity to these widget IDs; empty allows all.
+	Widgets []string `json:"widgets,omitempty"`
+	// Scopes restricts what the identity may do, see ReadScope; empty
+	// allows everything its roles allow.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// HasRole reports whether the identity has any of roles.
+func (id Identity) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		for _, own := range id.Roles {
+			if own == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the identity was granted scope or a scope that
+// includes it.
+func (id Identity) HasScope(scope string) bool {
+	if len(id.Scopes) == 0 {
+		return true
+	}
+	wanted := slices.Index(scopeOrder, scope)
+	for _, own := range id.Scopes {
+		if own == scope || (wanted >= 0 && slices.Index(scopeOrder, own) > wanted) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessWidget reports whether the identity may read widget id.
+func (id Identity) CanAccessWidget(widgetID string) bool {
+	if len(id.Widgets) == 0 {
+		return true
+	}
+	for _, allowed := range id.Widgets {
+		if allowed == widgetID {
+			return true
+		}
+	}
+	return false
+}
+
+type identityKey struct{}
+
+// ContextWithIdentity attaches id to ctx. Authentication middleware in front
+// of the server uses it to make the user known to auditing and rate limits.
+func ContextWithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the request, if any.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok && id.Subject != ""
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -14,6 +19,7 @@ import (
 	"github.com/ankulikov/rapidmin/config"
 	"github.com/ankulikov/rapidmin/providers"
 	sqlprovider "github.com/ankulikov/rapidmin/providers/sql"
+	"github.com/ankulikov/rapidmin/tracing"
 )
 
 type dataResponse struct {
@@ -78,6 +84,13 @@ func TestServerConfigAndWidgetData(t *testing.T) {
 		t.Fatalf("expected 1 row, got %d", dataResp.Total)
 	}
 
+	query = url.Values{}
+	query.Set("q", "BOB@")
+	dataResp = fetchWidgetData(t, srv.URL, "", query)
+	if dataResp.Total != 1 || extractID(dataResp.Data[0]) != 3 {
+		t.Fatalf("expected search to match row id=3")
+	}
+
 	query = url.Values{}
 	query.Set("limit", "1")
 	dataResp = fetchWidgetData(t, srv.URL, "", query)
@@ -148,6 +161,657 @@ func TestServerPathPrefix(t *testing.T) {
 	}
 }
 
+func TestServerStatWidget(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	app, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	stat := fetchStat(t, srv.URL+"/api/widgets/users_count")
+	if stat.Value != float64(3) || stat.Delta == nil || *stat.Delta != 1 {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+	if stat.DeltaPercent == nil || *stat.DeltaPercent != 50 {
+		t.Fatalf("unexpected delta percent %+v", stat.DeltaPercent)
+	}
+
+	stat = fetchStat(t, srv.URL+"/api/widgets/users_count?tags=vip")
+	if stat.Value != float64(2) {
+		t.Fatalf("expected filtered stat value 2, got %v", stat.Value)
+	}
+}
+
+func fetchStat(t *testing.T, endpoint string) providers.StatResponse {
+	t.Helper()
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		t.Fatalf("stat request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("stat status: %d", resp.StatusCode)
+	}
+
+	var payload providers.StatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode stat: %v", err)
+	}
+	return payload
+}
+
+func TestServerChartWidget(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	app, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/api/widgets/users_by_month?tags=vip")
+	if err != nil {
+		t.Fatalf("chart request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("chart status: %d", resp.StatusCode)
+	}
+
+	var chart providers.ChartResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chart); err != nil {
+		t.Fatalf("decode chart: %v", err)
+	}
+
+	expectedLabels := []any{"2023-12-01", "2024-01-01"}
+	if !reflect.DeepEqual(chart.Labels, expectedLabels) {
+		t.Fatalf("expected labels %v, got %v", expectedLabels, chart.Labels)
+	}
+	if len(chart.Series) != 2 || chart.Series[1].ID != "avg_age" {
+		t.Fatalf("unexpected series %+v", chart.Series)
+	}
+	if !reflect.DeepEqual(chart.Series[1].Data, []any{float64(45), float64(25)}) {
+		t.Fatalf("unexpected avg_age data %v", chart.Series[1].Data)
+	}
+}
+
+func TestServerPageFilters(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	app, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	query := url.Values{}
+	query.Set("min_age", "30")
+	dataResp := fetchWidgetData(t, srv.URL, "", query)
+	if dataResp.Total != 2 {
+		t.Fatalf("expected 2 rows, got %d", dataResp.Total)
+	}
+
+	stat := fetchStat(t, srv.URL+"/api/widgets/users_count?min_age=30")
+	if stat.Value != float64(3) {
+		t.Fatalf("expected unmapped stat to ignore page filter, got %v", stat.Value)
+	}
+}
+
+func TestServerPageData(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	cfg := sampleConfig()
+	cfg.Pages[0].Widgets = append(cfg.Pages[0].Widgets, config.Widget{
+		ID:       "broken",
+		Type:     "table",
+		Provider: config.ProviderSpec{Name: "missing"},
+	})
+	app, err := New(cfg, providerRegistry, WithBatchWorkers(2))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/api/pages/users/data?min_age=30&users_table:limit=1&limit=0")
+	if err != nil {
+		t.Fatalf("page data request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("page data status: %d", resp.StatusCode)
+	}
+
+	var payload map[string]struct {
+		Data  json.RawMessage `json:"data"`
+		Error *errorPayload   `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode page data: %v", err)
+	}
+	if len(payload) != 4 {
+		t.Fatalf("expected 4 widget results, got %d", len(payload))
+	}
+
+	var table dataResponse
+	if err := json.Unmarshal(payload["users_table"].Data, &table); err != nil {
+		t.Fatalf("decode table data: %v", err)
+	}
+	if len(table.Data) != 1 || !table.HasMore {
+		t.Fatalf("expected 1 table row with more, got %d (has_more %v)", len(table.Data), table.HasMore)
+	}
+
+	var stat providers.StatResponse
+	if err := json.Unmarshal(payload["users_count"].Data, &stat); err != nil {
+		t.Fatalf("decode stat data: %v", err)
+	}
+	if stat.Value != float64(3) {
+		t.Fatalf("expected stat value 3, got %v", stat.Value)
+	}
+
+	if broken := payload["broken"].Error; broken == nil || broken.Code != "config_error" || broken.Message != "unknown provider" {
+		t.Fatalf("expected broken widget error, got %+v", payload["broken"])
+	}
+
+	resp, err = http.Get(srv.URL + "/api/pages/nope/data")
+	if err != nil {
+		t.Fatalf("page data request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown page, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerConditionalRequestsAndCompression(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	app, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	for _, path := range []string{"/api/config", "/"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s request: %v", path, err)
+		}
+		resp.Body.Close()
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: expected ETag header", path)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set("If-None-Match", etag)
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s conditional request: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: expected 304, got %d", path, resp.StatusCode)
+		}
+	}
+
+	for _, encoding := range []string{"gzip", "br"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/config", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("compressed request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("Content-Encoding") != encoding {
+			t.Fatalf("expected %s encoding, got %q", encoding, resp.Header.Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestServerErrorResponses(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	cfg := sampleConfig()
+	cfg.Pages[0].Widgets = append(cfg.Pages[0].Widgets, config.Widget{
+		ID:       "bad_sql",
+		Type:     "table",
+		Provider: config.ProviderSpec{Name: "db", SQL: &config.SQLSpec{Query: "SELECT nope FROM missing_table"}},
+	})
+	app, err := New(cfg, providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		path    string
+		status  int
+		code    string
+		field   string
+		message string
+	}{
+		{"/api/widgets/users_table?created.between=2024-01-01", http.StatusBadRequest, "invalid_filter", "created",
+			"filter operator 'between' requires at least two values"},
+		{"/api/widgets/nope", http.StatusNotFound, "not_found", "", "not found"},
+		{"/api/widgets/bad_sql", http.StatusBadGateway, "database_error", "", "database query failed"},
+	}
+	for _, tc := range tests {
+		resp, err := http.Get(srv.URL + tc.path)
+		if err != nil {
+			t.Fatalf("%s request: %v", tc.path, err)
+		}
+		var body errorResponse
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s decode: %v", tc.path, err)
+		}
+		if resp.StatusCode != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.path, tc.status, resp.StatusCode)
+		}
+		expected := errorPayload{Code: providers.ErrorCode(tc.code), Message: tc.message, Field: tc.field}
+		if body.Error != expected {
+			t.Fatalf("%s: expected error %+v, got %+v", tc.path, expected, body.Error)
+		}
+	}
+}
+
+func TestServerHealthAndMetrics(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	cfg := sampleConfig()
+	cfg.PathPrefix = "/ops"
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	app, err := New(cfg, providerRegistry, WithLogger(logger))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	for path, status := range map[string]int{"/ops/healthz": http.StatusOK, "/ops/readyz": http.StatusOK} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("%s request: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+
+	fetchWidgetData(t, srv.URL, "/ops", url.Values{"age.gt": {"40"}})
+	resp, err := http.Get(srv.URL + "/ops/api/widgets/nope")
+	if err != nil {
+		t.Fatalf("data request: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(srv.URL + "/ops/metrics")
+	if err != nil {
+		t.Fatalf("metrics request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, line := range []string{
+		`rapidmin_http_requests_total{widget="users_table",status="200"} 1`,
+		`rapidmin_http_requests_total{widget="",status="404"} 1`,
+		`rapidmin_provider_rows_total{provider="db",widget="users_table"} 1`,
+		`rapidmin_provider_query_duration_seconds_count{provider="db",widget="users_table"} 1`,
+		`rapidmin_errors_total{code="not_found"} 1`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("metrics missing %q:\n%s", line, body)
+		}
+	}
+	for _, entry := range []string{
+		`msg="widget request" widget=users_table path=/api/widgets/users_table status=200 rows=1`,
+		`level=DEBUG msg="request failed" code=not_found`,
+	} {
+		if !strings.Contains(logs.String(), entry) {
+			t.Fatalf("logs missing %q:\n%s", entry, logs.String())
+		}
+	}
+
+	_ = db.Close()
+	resp, err = http.Get(srv.URL + "/ops/readyz")
+	if err != nil {
+		t.Fatalf("readyz request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected readyz 503 after db close, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerTracing(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	exporter := tracing.NewInMemoryExporter()
+	app, err := New(sampleConfig(), providerRegistry,
+		WithTracer(tracing.NewTracer(exporter)))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/widgets/users_table?age.gt=40", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("data request: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := map[string]tracing.SpanData{}
+	for _, span := range exporter.Spans() {
+		spans[span.Name] = span
+	}
+	httpSpan, fetchSpan, sqlSpan := spans["HTTP GET"], spans["provider.fetch"], spans["sql.query"]
+	if httpSpan.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("request span did not continue the incoming trace: %+v", spans)
+	}
+	if httpSpan.Attribute("http.status_code") != http.StatusOK {
+		t.Fatalf("unexpected request span attributes: %+v", httpSpan.Attributes)
+	}
+	if fetchSpan.Parent != httpSpan.Context || sqlSpan.Parent != fetchSpan.Context {
+		t.Fatalf("spans are not nested: %+v", spans)
+	}
+	for _, span := range []tracing.SpanData{fetchSpan, sqlSpan} {
+		if span.Attribute("widget.id") != "users_table" || span.Attribute("rows") != 1 {
+			t.Fatalf("unexpected %s attributes: %+v", span.Name, span.Attributes)
+		}
+	}
+	if fetchSpan.Attribute("provider.name") != "db" || sqlSpan.Attribute("db.system") != "sqlite3" {
+		t.Fatalf("missing provider attributes: %+v %+v", fetchSpan.Attributes, sqlSpan.Attributes)
+	}
+}
+
+func TestServerMountedInstances(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	cfg := sampleConfig()
+	cfg.PathPrefix = "/admin"
+	first, err := New(cfg, providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	second, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	if first.Handler() != first.Handler() {
+		t.Fatalf("expected Handler to be idempotent")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", first)
+	mux.Handle("/reports/", http.StripPrefix("/reports", second))
+	legacyCfg := sampleConfig()
+	legacyCfg.PathPrefix = "/legacy"
+	if _, err := New(legacyCfg, providerRegistry, WithMux(mux)); err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	query := url.Values{"age.gt": {"40"}}
+	for _, prefix := range []string{"/admin", "/reports", "/legacy"} {
+		if dataResp := fetchWidgetData(t, srv.URL, prefix, query); dataResp.Total != 1 {
+			t.Fatalf("%s: expected 1 row, got %d", prefix, dataResp.Total)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/reports/api/widgets/users_table", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET" {
+		t.Fatalf("expected 405 with Allow GET, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
+
+func TestServerAuditLog(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	var out bytes.Buffer
+	sink := NewJSONLinesAuditSink(&out, 0)
+	app, err := New(sampleConfig(), providerRegistry, WithAuditSink(sink))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), Identity{Subject: "ann"})))
+	}))
+	t.Cleanup(srv.Close)
+
+	fetchWidgetData(t, srv.URL, "", url.Values{"age.gt": {"40"}, "q": {"bo"}})
+	resp, err := http.Get(srv.URL + "/api/widgets/users_table?age.between=1")
+	if err != nil {
+		t.Fatalf("data request: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := app.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	var events []map[string]any
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event map[string]any
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("decode audit event: %v", err)
+		}
+		delete(event, "time")
+		delete(event, "duration_ms")
+		events = append(events, event)
+	}
+
+	expected := []map[string]any{
+		{
+			"user": "ann", "action": "view", "widget_id": "users_table", "filters": map[string]any{"age.gt": []any{"40"}},
+			"search": "bo", "rows": float64(1), "outcome": "success",
+		},
+		{
+			"user": "ann", "action": "view", "widget_id": "users_table", "filters": map[string]any{"age.between": []any{"1"}},
+			"rows": float64(0), "outcome": "invalid_filter",
+		},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("unexpected audit events:\n%v\nexpected:\n%v", events, expected)
+	}
+}
+
+func TestServerFilterValues(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	app, err := New(sampleConfig(), providerRegistry)
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	values := fetchFilterValues(t, srv.URL+"/api/widgets/users_table/filters/tags/values")
+	expected := []config.ValueOption{{Value: "active", Label: "ACTIVE"}, {Value: "vip", Label: "VIP"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected values %v, got %v", expected, values)
+	}
+
+	values = fetchFilterValues(t, srv.URL+"/api/widgets/users_table/filters/tags/values?q=vi")
+	expected = []config.ValueOption{{Value: "vip", Label: "VIP"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected values %v, got %v", expected, values)
+	}
+
+	if _, err := db.Exec(`INSERT INTO users (id, name, tag) VALUES (4, 'Eve', 'banned')`); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	values = fetchFilterValues(t, srv.URL+"/api/widgets/users_table/filters/tags/values")
+	if len(values) != 2 {
+		t.Fatalf("expected cached values, got %v", values)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/widgets/users_table/filters/name/values")
+	if err != nil {
+		t.Fatalf("values request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for static filter, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerFilterValuesOfStatsAndPages(t *testing.T) {
+	db := setupSQLiteDB(t)
+	providerRegistry := providers.Registry{
+		"db": sqlprovider.NewWithDB(db),
+	}
+
+	cfg := sampleConfig()
+	valuesFrom := cfg.Pages[0].Widgets[0].Table.Filters[2].ValuesFrom
+	cfg.Pages[0].Widgets[1].Stat.Filters[0].ValuesFrom = valuesFrom
+	cfg.RateLimit = &config.RateLimitConfig{}
+	cfg.Pages[0].Widgets[1].RateLimit = &config.RateSpec{Rate: 0.1, Burst: 1}
+	cfg.Pages[0].Filters = append(cfg.Pages[0].Filters, config.FilterSpec{
+		ID: "tag", Title: "Tag", Type: "select_multi", Target: "tag", ValuesFrom: valuesFrom,
+	})
+
+	var out bytes.Buffer
+	app, err := New(cfg, providerRegistry, WithAuditSink(NewJSONLinesAuditSink(&out, 0)))
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	srv := httptest.NewServer(app.Handler())
+	t.Cleanup(srv.Close)
+
+	expected := []config.ValueOption{{Value: "active", Label: "ACTIVE"}, {Value: "vip", Label: "VIP"}}
+	for _, path := range []string{"/api/widgets/users_count/filters/tags/values", "/api/pages/users/filters/tag/values"} {
+		if values := fetchFilterValues(t, srv.URL+path); !reflect.DeepEqual(values, expected) {
+			t.Fatalf("%s: expected values %v, got %v", path, expected, values)
+		}
+	}
+
+	// Values share the rate limit of the widget that owns the filter.
+	resp, err := http.Get(srv.URL + "/api/widgets/users_count/filters/tags/values?q=vi")
+	if err != nil {
+		t.Fatalf("values request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "10" {
+		t.Fatalf("expected 429 with Retry-After 10, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	for path, status := range map[string]int{
+		"/api/pages/users/filters/min_age/values": http.StatusNotFound,
+		"/api/pages/orders/filters/tag/values":    http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("values request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("%s: expected %d, got %d", path, status, resp.StatusCode)
+		}
+	}
+
+	if err := app.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	var widgets []string
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var event struct {
+			WidgetID string `json:"widget_id"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			t.Fatalf("decode audit event: %v", err)
+		}
+		widgets = append(widgets, event.WidgetID)
+	}
+	if expected := []string{"users_count.tags", "page:users.tag"}; !reflect.DeepEqual(widgets, expected) {
+		t.Fatalf("expected audit events for %v, got %v", expected, widgets)
+	}
+}
+
+func fetchFilterValues(t *testing.T, endpoint string) []config.ValueOption {
+	t.Helper()
+	resp, err := http.Get(endpoint)
+	if err != nil {
+		t.Fatalf("values request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("values status: %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Values []config.ValueOption `json:"values"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode values: %v", err)
+	}
+	return payload.Values
+}
+
 func fetchWidgetData(t *testing.T, baseURL, prefix string, query url.Values) dataResponse {
 	t.Helper()
 	endpoint := baseURL + prefix + "/api/widgets/users_table"
@@ -204,6 +868,40 @@ func setupSQLiteDB(t *testing.T) *sqlx.DB {
 	return db
 }
 
+func TestServerConfigColumnMetadata(t *testing.T) {
+	cfg := config.AppConfig{Pages: []config.Page{{Slug: "ops", Widgets: []config.Widget{{
+		ID:   "orders",
+		Type: config.TableWidget,
+		Table: &config.TableSpec{Columns: []config.ColumnSpec{
+			{ID: "status", Render: &config.ColumnRender{Type: config.BadgeRender, Colors: map[string]string{"paid": "green"}}},
+			{ID: "created_at", Render: &config.ColumnRender{Type: config.DateTimeRender, Relative: true}},
+			{ID: "email", Mask: &config.MaskSpec{Type: config.PartialMask, ExemptRoles: []string{"support"}}},
+			{ID: "total", Format: &config.ColumnFormat{Type: config.CurrencyFormat, Currency: "EUR"}},
+		}},
+	}}}}}
+	app, err := New(cfg, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`"render":{"type":"badge","colors":{"paid":"green"}}`,
+		`"render":{"type":"datetime","relative":true}`,
+		`"mask":{"type":"partial"}`,
+		`"format":{"type":"currency"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("config missing %s: %s", want, body)
+		}
+	}
+	if strings.Contains(body, "support") || strings.Contains(body, "EUR") {
+		t.Fatalf("config leaks server-side column settings: %s", body)
+	}
+}
+
 func sampleConfig() config.AppConfig {
 	return config.AppConfig{
 		Title: "Test",
@@ -216,6 +914,15 @@ func sampleConfig() config.AppConfig {
 			{
 				Slug:  "users",
 				Title: "Users",
+				Filters: []config.FilterSpec{
+					{
+						ID:        "min_age",
+						Title:     "Older than",
+						Type:      "number",
+						Target:    "age",
+						Operators: []config.FilterOperator{"gt"},
+					},
+				},
 				Widgets: []config.Widget{
 					{
 						ID:    "users_table",
@@ -237,6 +944,9 @@ func sampleConfig() config.AppConfig {
 								{ID: "name", Title: "name"},
 								{ID: "email", Title: "email"},
 							},
+							Search: &config.SearchSpec{
+								Columns: []string{"name", "email"},
+							},
 							Filters: []config.FilterSpec{
 								{
 									ID:        "name",
@@ -258,6 +968,14 @@ func sampleConfig() config.AppConfig {
 									Type:      "select_multi",
 									Target:    "tag",
 									Operators: []config.FilterOperator{"in"},
+									ValuesFrom: &config.ValuesSourceSpec{
+										Provider: config.ProviderSpec{
+											Name: "db",
+											SQL: &config.SQLSpec{
+												Query: `SELECT DISTINCT tag AS value, upper(tag) AS label FROM users ORDER BY value`,
+											},
+										},
+									},
 								},
 								{
 									ID:        "created",
@@ -269,6 +987,49 @@ func sampleConfig() config.AppConfig {
 							},
 						},
 					},
+					{
+						ID:    "users_count",
+						Title: "Users",
+						Type:  "stat",
+						Provider: config.ProviderSpec{
+							Name: "db",
+							SQL: &config.SQLSpec{
+								Query: `SELECT id, tag, CASE WHEN id < 3 THEN id END AS previous_id FROM users`,
+							},
+						},
+						PageFilters: map[string]string{"min_age": ""},
+						Stat: &config.StatSpec{
+							Value:     "id",
+							Previous:  "previous_id",
+							Aggregate: config.CountAggregation,
+							Filters: []config.FilterSpec{
+								{ID: "tags", Title: "Tags", Type: "select_multi", Target: "tag"},
+							},
+						},
+					},
+					{
+						ID:    "users_by_month",
+						Title: "Signups",
+						Type:  "chart",
+						Provider: config.ProviderSpec{
+							Name: "db",
+							SQL: &config.SQLSpec{
+								Query: `SELECT created_at, age, tag FROM users`,
+							},
+						},
+						Chart: &config.ChartSpec{
+							Kind:      config.BarChart,
+							Dimension: "created_at",
+							Bucket:    config.MonthBucket,
+							Measures: []config.MeasureSpec{
+								{Title: "Users", Aggregate: config.CountAggregation},
+								{Column: "age", Aggregate: config.AvgAggregation},
+							},
+							Filters: []config.FilterSpec{
+								{ID: "tags", Title: "Tags", Type: "select_multi", Target: "tag"},
+							},
+						},
+					},
 				},
 			},
 		},
@@ -291,3 +1052,13 @@ func extractID(row map[string]any) int {
 		return 0
 	}
 }
+
+func TestServerMaskedPaginationRejected(t *testing.T) {
+	cfg := sampleConfig()
+	widget := &cfg.Pages[0].Widgets[0]
+	widget.Provider.SQL.Pagination = &config.PaginationSpec{Column: "email", Order: "asc"}
+	widget.Table.Columns[2].Mask = &config.MaskSpec{Type: config.PartialMask, ExemptRoles: []string{"support"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "pagination column email cannot be masked") {
+		t.Fatalf("expected masked pagination column to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, bound := range durationBuckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// labelKey joins label values; label names are fixed per metric.
+type labelKey string
+
+func makeLabelKey(values ...string) labelKey {
+	return labelKey(strings.Join(values, "\x00"))
+}
+
+func (k labelKey) values() []string {
+	return strings.Split(string(k), "\x00")
+}
+
+// metrics collects request and query statistics and exposes them in the
+// Prometheus text format.
+type metrics struct {
+	mu              sync.Mutex
+	requests        map[labelKey]uint64
+	requestDuration map[labelKey]*histogram
+	queryDuration   map[labelKey]*histogram
+	rows            map[labelKey]uint64
+	errors          map[labelKey]uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:        map[labelKey]uint64{},
+		requestDuration: map[labelKey]*histogram{},
+		queryDuration:   map[labelKey]*histogram{},
+		rows:            map[labelKey]uint64{},
+		errors:          map[labelKey]uint64{},
+	}
+}
+
+func (m *metrics) observeRequest(widget string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[makeLabelKey(widget, strconv.Itoa(status))]++
+	observeHistogram(m.requestDuration, makeLabelKey(widget), duration)
+}
+
+func (m *metrics) observeQuery(provider, widget string, rows int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := makeLabelKey(provider, widget)
+	observeHistogram(m.queryDuration, key, duration)
+	m.rows[key] += uint64(rows)
+}
+
+func (m *metrics) observeError(code string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.errors[makeLabelKey(code)]++
+}
+
+func observeHistogram(histograms map[labelKey]*histogram, key labelKey, duration time.Duration) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{}
+		histograms[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeCounter(w, "rapidmin_http_requests_total", "Widget data requests by widget and status.",
+		[]string{"widget", "status"}, m.requests)
+	writeHistogram(w, "rapidmin_http_request_duration_seconds", "Widget data request latency.",
+		[]string{"widget"}, m.requestDuration)
+	writeHistogram(w, "rapidmin_provider_query_duration_seconds", "Provider fetch duration.",
+		[]string{"provider", "widget"}, m.queryDuration)
+	writeCounter(w, "rapidmin_provider_rows_total", "Rows returned by providers.",
+		[]string{"provider", "widget"}, m.rows)
+	writeCounter(w, "rapidmin_errors_total", "API errors by code.",
+		[]string{"code"}, m.errors)
+}
+
+func writeCounter(w io.Writer, name, help string, labels []string, values map[labelKey]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, formatLabels(labels, key.values()), values[key])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help string, labels []string, values map[labelKey]*histogram) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, key := range sortedKeys(values) {
+		h := values[key]
+		base := formatLabels(labels, key.values())
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, base,
+				strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, base, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, base, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, base, h.count)
+	}
+}
+
+func formatLabels(names, values []string) string {
+	parts := make([]string, 0, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", name, strconv.Quote(value)))
+	}
+	return strings.Join(parts, ",")
+}
+
+func sortedKeys[V any](values map[labelKey]V) []labelKey {
+	keys := make([]labelKey, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+type requestInfoKey struct{}
+
+// requestInfo collects details of a widget request for its log entry.
+type requestInfo struct {
+	rows int
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// instrumentWidget records request counts and latencies of widget endpoints
+// and logs each request. Unknown widget IDs are reported with an empty label
+// to bound cardinality.
+func (s *Server) instrumentWidget(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		info := &requestInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		next(rec, r)
+
+		widgetID := ""
+		if _, found := s.findWidget(r.PathValue("id")); found {
+			widgetID = r.PathValue("id")
+		}
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.observeWidgetRequest(r, widgetID, status, info.rows, time.Since(start))
+	}
+}
+
+// observeWidgetRequest records and logs one widget request; page data
+// requests report each of their widgets.
+func (s *Server) observeWidgetRequest(r *http.Request, widgetID string, status, rows int, duration time.Duration) {
+	s.metrics.observeRequest(widgetID, status, duration)
+	s.logger.LogAttrs(r.Context(), slog.LevelInfo, "widget request",
+		slog.String("widget", widgetID),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int("rows", rows),
+		slog.Duration("duration", duration),
+	)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.metrics.write(w)
+	if dropped, ok := s.auditDropped(); ok {
+		fmt.Fprintf(w, "# HELP %[1]s Audit events lost because a sink was full or failed.\n# TYPE %[1]s counter\n%[1]s %[2]d\n",
+			"rapidmin_audit_dropped_total", dropped)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+const defaultBatchWorkers = 4
+
+type widgetResult struct {
+	Data  any           `json:"data,omitempty"`
+	Error *errorPayload `json:"error,omitempty"`
+}
+
+// handlePageData fetches every widget of a page concurrently. Page filter
+// params are passed to every widget; other params apply to one widget when
+// prefixed with its ID, e.g. "orders:limit=10" or "orders:status=paid". Each
+// widget is rate limited, capped and recorded like a request for its own
+// data, and a failing widget reports its error, with retry_after when it was
+// rate limited, without failing the batch.
+func (s *Server) handlePageData(w http.ResponseWriter, r *http.Request) {
+	page, ok := s.findPage(r.PathValue("slug"))
+	if !ok {
+		s.writeError(w, errNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	results := make(map[string]widgetResult, len(page.Widgets))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.batchWorkers)
+
+	for _, widget := range page.Widgets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(widget config.Widget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var result widgetResult
+			start := time.Now()
+			info := &requestInfo{}
+			payload, wait, err := s.fetchPageWidget(r, widget, widgetQuery(query, page, widget), info)
+			status := http.StatusOK
+			if err != nil {
+				typed := providers.AsError(err)
+				s.metrics.observeError(string(typed.Code))
+				s.logError(typed)
+				payload := newErrorPayload(typed)
+				if wait > 0 {
+					payload.RetryAfter = retryAfterSeconds(wait)
+				}
+				result.Error = &payload
+				status = typed.Code.Status()
+			} else {
+				result.Data = payload
+			}
+			s.observeWidgetRequest(r, widget.ID, status, info.rows, time.Since(start))
+
+			mu.Lock()
+			results[widget.ID] = result
+			mu.Unlock()
+		}(widget)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(results)
+}
+
+// fetchPageWidget fetches one widget of a page. Widgets of a page wait for
+// their provider's in-flight slots instead of rejecting each other; rate
+// limited widgets report how long to wait.
+func (s *Server) fetchPageWidget(r *http.Request, widget config.Widget, query url.Values,
+	info *requestInfo) (any, time.Duration, error) {
+	release, wait, err := s.acquireWidget(r, widget, true)
+	if err != nil {
+		return nil, wait, err
+	}
+	defer release()
+
+	ctx := context.WithValue(r.Context(), requestInfoKey{}, info)
+	payload, err := s.fetchWidget(ctx, widget, query)
+	return payload, 0, err
+}
+
+// widgetQuery returns the params of a page data request that apply to
+// widget: page filters and the params prefixed with "<widget ID>:".
+func widgetQuery(query url.Values, page config.Page, widget config.Widget) url.Values {
+	values := url.Values{}
+	prefix := widget.ID + ":"
+	for key, vals := range query {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			values[name] = vals
+			continue
+		}
+		filterID, _, _ := strings.Cut(key, ".")
+		if slices.ContainsFunc(page.Filters, func(f config.FilterSpec) bool { return f.ID == filterID }) {
+			if _, ok := values[key]; !ok {
+				values[key] = vals
+			}
+		}
+	}
+	return values
+}
+
+func (s *Server) findPage(slug string) (config.Page, bool) {
+	for _, page := range s.cfg.Pages {
+		if page.Slug == slug {
+			return page, true
+		}
+	}
+
+	return config.Page{}, false
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+// maxBuckets is a soft bound on per-user and per-widget buckets. Once
+// reached, full buckets are forgotten since they behave like fresh ones.
+const maxBuckets = 10000
+
+type tokenBucket struct {
+	spec   config.RateSpec
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(spec config.RateSpec, now time.Time) *tokenBucket {
+	return &tokenBucket{spec: spec, tokens: float64(spec.Burst), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.spec.Burst), b.tokens+elapsed*b.spec.Rate)
+		b.last = now
+	}
+}
+
+// wait returns how long until a token is available.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if b.spec.Rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - b.tokens) / b.spec.Rate * float64(time.Second))
+}
+
+func (b *tokenBucket) full() bool {
+	return b.tokens >= float64(b.spec.Burst)
+}
+
+// rateLimiter holds the global, per-widget and per-user token buckets. A
+// request only consumes tokens when every bucket it needs has one, so a
+// rejected request does not drain the other buckets.
+type rateLimiter struct {
+	cfg config.RateLimitConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	global  *tokenBucket
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	l := &rateLimiter{cfg: cfg, now: time.Now, buckets: map[string]*tokenBucket{}}
+	if validRate(cfg.Global) {
+		l.global = newTokenBucket(*cfg.Global, l.now())
+	}
+	return l
+}
+
+func validRate(spec *config.RateSpec) bool {
+	return spec != nil && spec.Burst > 0
+}
+
+// allow takes a token for a request to widget by user and otherwise returns
+// how long to wait before retrying.
+func (l *rateLimiter) allow(widget config.Widget, user string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var buckets []*tokenBucket
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	widgetSpec := l.cfg.PerWidget
+	if widget.RateLimit != nil {
+		widgetSpec = widget.RateLimit
+	}
+	if validRate(widgetSpec) {
+		buckets = append(buckets, l.bucket("widget:"+widget.ID, *widgetSpec, now))
+	}
+	if validRate(l.cfg.PerUser) {
+		buckets = append(buckets, l.bucket("user:"+user, *l.cfg.PerUser, now))
+	}
+
+	var wait time.Duration
+	for _, bucket := range buckets {
+		bucket.refill(now)
+		wait = max(wait, bucket.wait())
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+	return true, 0
+}
+
+func (l *rateLimiter) bucket(key string, spec config.RateSpec, now time.Time) *tokenBucket {
+	bucket, ok := l.buckets[key]
+	if ok && bucket.spec == spec {
+		return bucket
+	}
+	if len(l.buckets) >= maxBuckets {
+		for k, b := range l.buckets {
+			b.refill(now)
+			if b.full() {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	bucket = newTokenBucket(spec, now)
+	l.buckets[key] = bucket
+	return bucket
+}
+
+// newInFlightLimits creates a semaphore for every provider with
+// max_in_flight.
+func newInFlightLimits(providerConfigs map[string]config.ProviderConfig) map[string]chan struct{} {
+	limits := map[string]chan struct{}{}
+	for name, providerConfig := range providerConfigs {
+		if providerConfig.MaxInFlight > 0 {
+			limits[name] = make(chan struct{}, providerConfig.MaxInFlight)
+		}
+	}
+	return limits
+}
+
+// limitWidget applies rate limits and in-flight caps to widget data
+// requests. Rejected requests get 429 with Retry-After.
+func (s *Server) limitWidget(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		widget, ok := s.findWidget(r.PathValue("id"))
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		release, wait, err := s.acquireWidget(r, widget, false)
+		if err != nil {
+			writeRetryAfter(w, wait)
+			s.writeError(w, err)
+			return
+		}
+		defer release()
+
+		next(w, r)
+	}
+}
+
+// acquireWidget applies the rate limits and in-flight cap of widget to a
+// request. With block, it waits for an in-flight slot until the request is
+// canceled instead of rejecting it. On success the caller must call release
+// once the fetch is done; otherwise wait is how long the client should back
+// off.
+func (s *Server) acquireWidget(r *http.Request, widget config.Widget, block bool) (release func(), wait time.Duration, err error) {
+	if s.rateLimiter != nil {
+		if ok, wait := s.rateLimiter.allow(widget, clientKey(r)); !ok {
+			return nil, wait, errRateLimited
+		}
+	}
+
+	if sem, ok := s.inFlight[widget.Provider.Name]; ok {
+		if block {
+			select {
+			case sem <- struct{}{}:
+				return func() { <-sem }, 0, nil
+			case <-r.Context().Done():
+				return nil, 0, providers.AsError(r.Context().Err())
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+			return func() { <-sem }, 0, nil
+		default:
+			return nil, time.Second, errProviderBusy
+		}
+	}
+	return func() {}, 0, nil
+}
+
+// clientKey identifies the user of a request for per-user limits. Anonymous
+// requests are keyed by their remote address.
+func clientKey(r *http.Request) string {
+	if id, ok := IdentityFromContext(r.Context()); ok {
+		return "sub:" + id.Subject
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
+
+func writeRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+}
+
+// retryAfterSeconds rounds wait up to whole seconds, at least one.
+func retryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
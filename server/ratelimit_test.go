@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(config.RateLimitConfig{
+		PerWidget: &config.RateSpec{Rate: 1, Burst: 2},
+		PerUser:   &config.RateSpec{Rate: 0.5, Burst: 1},
+	})
+	limiter.now = func() time.Time { return now }
+
+	users := config.Widget{ID: "users"}
+	orders := config.Widget{ID: "orders", RateLimit: &config.RateSpec{Rate: 10, Burst: 1}}
+
+	steps := []struct {
+		widget config.Widget
+		user   string
+		allow  bool
+		wait   time.Duration
+	}{
+		{users, "ann", true, 0},
+		{users, "bob", true, 0},
+		// Widget bucket is empty; the user bucket of carl must stay full.
+		{users, "carl", false, time.Second},
+		{orders, "carl", true, 0},
+		{orders, "ann", false, 2 * time.Second},
+	}
+	for i, step := range steps {
+		allow, wait := limiter.allow(step.widget, step.user)
+		if allow != step.allow || wait != step.wait {
+			t.Fatalf("step %d: expected (%v, %v), got (%v, %v)", i, step.allow, step.wait, allow, wait)
+		}
+	}
+
+	now = now.Add(2 * time.Second)
+	if allow, _ := limiter.allow(users, "ann"); !allow {
+		t.Fatalf("expected buckets to refill")
+	}
+}
+
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProvider) Init(context.Context, string, config.ProviderConfig) error {
+	return nil
+}
+
+func (p *blockingProvider) Fetch(context.Context, config.Widget, providers.DataRequest) (providers.DataResponse, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return providers.DataResponse{}, nil
+}
+
+func TestWidgetLimits(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	cfg := config.AppConfig{
+		Providers: map[string]config.ProviderConfig{"db": {MaxInFlight: 1}},
+		RateLimit: &config.RateLimitConfig{PerUser: &config.RateSpec{Rate: 0.1, Burst: 2}},
+		Pages: []config.Page{{Slug: "p", Widgets: []config.Widget{
+			{ID: "slow", Type: config.TableWidget, Provider: config.ProviderSpec{Name: "db"}},
+		}}},
+	}
+	srv, err := New(cfg, providers.Registry{"db": provider})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	request := func(subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/widgets/slow", nil)
+		if subject != "" {
+			req = req.WithContext(ContextWithIdentity(req.Context(), Identity{Subject: subject}))
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- request("ann") }()
+	<-provider.started
+
+	rec := request("ann")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected in-flight 429, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	close(provider.release)
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", rec.Code)
+	}
+
+	rec = request("ann")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Fatalf("expected per-user 429 with Retry-After 10, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := request("bob"); rec.Code != http.StatusOK {
+		t.Fatalf("expected another user to pass, got %d", rec.Code)
+	}
+}
+
+func TestPageDataLimits(t *testing.T) {
+	provider := &blockingProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	cfg := config.AppConfig{
+		Providers: map[string]config.ProviderConfig{"db": {MaxInFlight: 1}},
+		RateLimit: &config.RateLimitConfig{PerUser: &config.RateSpec{Rate: 0.1, Burst: 2}},
+		Pages: []config.Page{{Slug: "p", Widgets: []config.Widget{
+			{ID: "slow", Type: config.TableWidget, Provider: config.ProviderSpec{Name: "db"}},
+		}}},
+	}
+	srv, err := New(cfg, providers.Registry{"db": provider})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	request := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/pages/p/data", nil))
+		return rec
+	}
+
+	done := make(chan struct{})
+	go func() {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/widgets/slow", nil))
+		close(done)
+	}()
+	<-provider.started
+
+	// The page waits for the in-flight slot instead of failing the widget.
+	page := make(chan *httptest.ResponseRecorder)
+	go func() { page <- request() }()
+	select {
+	case rec := <-page:
+		t.Fatalf("expected page data to wait for the in-flight slot, got %d %s", rec.Code, rec.Body.String())
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(provider.release)
+	<-done
+	if rec := <-page; !strings.Contains(rec.Body.String(), `"slow":{"data"`) {
+		t.Fatalf("expected slow widget data, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := request()
+	if !strings.Contains(rec.Body.String(), `"slow":{"error":{"code":"rate_limited","message":"rate limit exceeded, retry later","retry_after":10}`) {
+		t.Fatalf("expected slow widget to be rate limited with retry_after, got %d %s", rec.Code, rec.Body.String())
+	}
+}
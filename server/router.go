@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// router dispatches requests by method and path pattern. Patterns are made
+// of literal segments, {name} segments matching a single path segment and an
+// optional trailing {name...} segment matching the rest of the path. Matched
+// values are available through http.Request.PathValue.
+type router struct {
+	routes   []route
+	fallback http.HandlerFunc
+	// methodNotAllowed is called when the path matches but the method does
+	// not; the Allow header is already set. OPTIONS requests to known paths
+	// are answered with 204 and the Allow header instead.
+	methodNotAllowed http.HandlerFunc
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+func (rt *router) handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+
+	var allowed []string
+	for _, route := range rt.routes {
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		if !route.allows(r.Method) {
+			allowed = append(allowed, route.method)
+			continue
+		}
+		for name, value := range params {
+			r.SetPathValue(name, value)
+		}
+		route.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if len(allowed) > 0 {
+		if r.Method == http.MethodOptions {
+			allowed = append(allowed, http.MethodOptions)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		rt.methodNotAllowed(w, r)
+		return
+	}
+	rt.fallback(w, r)
+}
+
+// allows reports whether the route serves method. GET routes also serve HEAD.
+func (r route) allows(method string) bool {
+	return r.method == method || (r.method == http.MethodGet && method == http.MethodHead)
+}
+
+func (r route) match(segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, segment := range r.segments {
+		name, isParam := paramName(segment)
+		if isParam && strings.HasSuffix(name, "...") {
+			params[strings.TrimSuffix(name, "...")] = strings.Join(segments[i:], "/")
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case isParam:
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// splitPath splits a path into segments, ignoring leading and trailing
+// slashes.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := &router{
+		fallback: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+		methodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		},
+	}
+	echo := func(params ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			for _, name := range params {
+				_, _ = w.Write([]byte(name + "=" + r.PathValue(name) + ";"))
+			}
+		}
+	}
+	rt.handle(http.MethodGet, "/api/widgets/{id}", echo("id"))
+	rt.handle(http.MethodGet, "/api/widgets/{id}/filters/{filter}/values", echo("id", "filter"))
+	rt.handle(http.MethodDelete, "/api/widgets/{id}", echo("id"))
+	rt.handle(http.MethodGet, "/files/{path...}", echo("path"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/api/widgets/users", http.StatusOK, "id=users;", ""},
+		{http.MethodGet, "/api/widgets/users/", http.StatusOK, "id=users;", ""},
+		{http.MethodHead, "/api/widgets/users", http.StatusOK, "", ""},
+		{http.MethodDelete, "/api/widgets/users", http.StatusOK, "id=users;", ""},
+		{http.MethodGet, "/api/widgets/users/filters/genre/values", http.StatusOK, "id=users;filter=genre;", ""},
+		{http.MethodGet, "/files/a/b/c", http.StatusOK, "path=a/b/c;", ""},
+		{http.MethodGet, "/api/widgets", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/widgets/users/extra", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/widgets//filters/genre/values", http.StatusNotFound, "", ""},
+		{http.MethodPost, "/api/widgets/users", http.StatusMethodNotAllowed, "", "DELETE, GET"},
+		{http.MethodOptions, "/api/widgets/users", http.StatusNoContent, "", "DELETE, GET, OPTIONS"},
+		{http.MethodOptions, "/api/nope", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, rec.Code)
+		}
+		if tt.method != http.MethodHead && rec.Body.String() != tt.body {
+			t.Fatalf("%s %s: expected body %q, got %q", tt.method, tt.path, tt.body, rec.Body.String())
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Fatalf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+)
+
+const (
+	inlineHashesPlaceholder = "{{inline_hashes}}"
+
+	// defaultCSP allows the inlined UI bundle by hash and the web fonts it
+	// imports. Images may come from anywhere over HTTPS.
+	defaultCSP = "default-src 'self'; script-src 'self' {{inline_hashes}}; " +
+		"style-src 'self' {{inline_hashes}} https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; " +
+		"img-src 'self' data: https:; connect-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+	defaultFrameOptions   = "DENY"
+	defaultReferrerPolicy = "same-origin"
+	defaultHSTSMaxAge     = 180 * 24 * time.Hour
+)
+
+var inlineBlockPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)<script\b[^>]*>(.*?)</script>`),
+	regexp.MustCompile(`(?s)<style\b[^>]*>(.*?)</style>`),
+}
+
+// securityHeaders holds the resolved header values; empty values are
+// omitted.
+type securityHeaders struct {
+	csp            string
+	frameOptions   string
+	referrerPolicy string
+	hsts           string
+	trustedOrigins map[string]bool
+}
+
+func newSecurityHeaders(cfg *config.SecurityConfig, indexHTML []byte) securityHeaders {
+	if cfg == nil {
+		cfg = &config.SecurityConfig{}
+	}
+
+	headers := securityHeaders{
+		csp:            headerValue(cfg.ContentSecurityPolicy, defaultCSP),
+		frameOptions:   headerValue(cfg.FrameOptions, defaultFrameOptions),
+		referrerPolicy: headerValue(cfg.ReferrerPolicy, defaultReferrerPolicy),
+		trustedOrigins: map[string]bool{},
+	}
+	headers.csp = strings.ReplaceAll(headers.csp, inlineHashesPlaceholder, strings.Join(inlineHashes(indexHTML), " "))
+
+	maxAge := cfg.HSTSMaxAge
+	if maxAge == 0 {
+		maxAge = defaultHSTSMaxAge
+	}
+	if maxAge > 0 {
+		headers.hsts = "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	}
+
+	for _, origin := range cfg.TrustedOrigins {
+		headers.trustedOrigins[strings.TrimSuffix(strings.ToLower(origin), "/")] = true
+	}
+	return headers
+}
+
+func headerValue(value, fallback string) string {
+	switch value {
+	case "":
+		return fallback
+	case "-":
+		return ""
+	}
+	return value
+}
+
+// inlineHashes returns CSP hash sources of the scripts and styles inlined in
+// the page.
+func inlineHashes(page []byte) []string {
+	var hashes []string
+	for _, pattern := range inlineBlockPatterns {
+		for _, match := range pattern.FindAllSubmatch(page, -1) {
+			if len(match[1]) == 0 {
+				continue
+			}
+			sum := sha256.Sum256(match[1])
+			hashes = append(hashes, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+		}
+	}
+	return hashes
+}
+
+// secure sets security headers on every response and rejects cross-origin
+// state-changing requests.
+func (s *Server) secure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		setHeader(header, "Content-Security-Policy", s.security.csp)
+		setHeader(header, "X-Frame-Options", s.security.frameOptions)
+		setHeader(header, "Referrer-Policy", s.security.referrerPolicy)
+		if isHTTPS(r) {
+			setHeader(header, "Strict-Transport-Security", s.security.hsts)
+		}
+
+		if !s.sameOriginRequest(r) {
+			s.writeError(w, errCrossOrigin)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setHeader(header http.Header, key, value string) {
+	if value != "" {
+		header.Set(key, value)
+	}
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// sameOriginRequest protects against CSRF. Safe methods always pass. Other
+// requests from browsers must come from the server's own origin or a
+// trusted one, as reported by Sec-Fetch-Site, Origin or Referer. Requests
+// without any of these headers are not sent by browsers and pass.
+func (s *Server) sameOriginRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		if referer, err := url.Parse(r.Header.Get("Referer")); err == nil && referer.Host != "" {
+			origin = referer.Scheme + "://" + referer.Host
+		}
+	}
+	if origin != "" && origin != "null" && s.security.trustedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return false
+	}
+
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, r.Host)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func TestInlineHashes(t *testing.T) {
+	page := []byte("<html><script type=\"module\">alert('<script><\\/script>')</script><script src=\"/x.js\"></script>" +
+		"<style rel=\"stylesheet\">body{color:red}</style></html>")
+	expected := []string{
+		"'sha256-DHCRdcpuNkXwsxqE8B2zpEKk5msndlt836U84/BqTCM='",
+		"'sha256-FcQqt3aNlV7AZnGV4zkQRVeCeJOxbMPnQSx258L803E='",
+	}
+	got := inlineHashes(page)
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Fatalf("inlineHashes() = %v, expected %v", got, expected)
+	}
+}
+
+func TestSameOriginRequest(t *testing.T) {
+	srv := &Server{security: newSecurityHeaders(&config.SecurityConfig{
+		TrustedOrigins: []string{"https://tools.example.com/"},
+	}, nil)}
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		allowed bool
+	}{
+		{"safe method", http.MethodGet, map[string]string{"Origin": "https://evil.example"}, true},
+		{"no browser headers", http.MethodDelete, nil, true},
+		{"same origin", http.MethodDelete, map[string]string{"Origin": "http://admin.local"}, true},
+		{"fetch metadata same origin", http.MethodDelete, map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{"trusted origin", http.MethodPost, map[string]string{
+			"Origin": "https://tools.example.com", "Sec-Fetch-Site": "cross-site",
+		}, true},
+		{"cross site", http.MethodDelete, map[string]string{
+			"Origin": "https://evil.example", "Sec-Fetch-Site": "cross-site",
+		}, false},
+		{"foreign origin", http.MethodDelete, map[string]string{"Origin": "https://evil.example"}, false},
+		{"foreign referer", http.MethodDelete, map[string]string{"Referer": "https://evil.example/page"}, false},
+		{"null origin", http.MethodPost, map[string]string{"Origin": "null"}, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://admin.local/api/admin/cache/users", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		if got := srv.sameOriginRequest(req); got != tt.allowed {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.allowed, got)
+		}
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	cfg := config.AppConfig{Security: &config.SecurityConfig{
+		FrameOptions: "-",
+		HSTSMaxAge:   time.Hour,
+	}}
+	srv, err := New(cfg, providers.Registry{})
+	if err != nil {
+		t.Fatalf("server init: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	header := rec.Header()
+	csp := header.Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src 'self' 'sha256-") || strings.Contains(csp, inlineHashesPlaceholder) {
+		t.Fatalf("expected CSP with inline hashes, got %q", csp)
+	}
+	for _, hash := range inlineHashes(rec.Body.Bytes()) {
+		if !strings.Contains(csp, hash) {
+			t.Fatalf("CSP misses hash %s of the served page", hash)
+		}
+	}
+	if header.Get("X-Frame-Options") != "" {
+		t.Fatalf("expected X-Frame-Options to be disabled")
+	}
+	if header.Get("Referrer-Policy") != "same-origin" || header.Get("Strict-Transport-Security") != "max-age=3600" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/admin/cache/users", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":"forbidden"`) {
+		t.Fatalf("expected cross-origin DELETE to be rejected, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Fatalf("expected no HSTS over plain HTTP")
+	}
+}
@@ -1,14 +1,22 @@
 package server
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"fmt"
 	"html"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ankulikov/rapidmin/config"
 	"github.com/ankulikov/rapidmin/providers"
+	"github.com/ankulikov/rapidmin/tracing"
 )
 
 //go:embed web/index.html
@@ -16,14 +24,34 @@ var indexFS embed.FS
 
 const indexPath = "web/index.html"
 
+const shutdownTimeout = 10 * time.Second
+
 type Server struct {
-	cfg          config.AppConfig
-	providers    providers.Registry
-	mux          *http.ServeMux
-	indexHTML    []byte
-	pathPrefix   string
-	renderedOnce sync.Once
-	renderedHTML []byte
+	cfg            config.AppConfig
+	providers      providers.Registry
+	handler        http.Handler
+	indexHTML      []byte
+	pathPrefix     string
+	renderedOnce   sync.Once
+	renderedHTML   []byte
+	renderedETag   string
+	configOnce     sync.Once
+	configJSON     []byte
+	configETag     string
+	configErr      error
+	valuesCache    valuesCache
+	batchWorkers   int
+	metrics        *metrics
+	logger         *slog.Logger
+	tracer         tracing.Tracer
+	auditSinks     []AuditSink
+	rateLimiter    *rateLimiter
+	inFlight       map[string]chan struct{}
+	security       securityHeaders
+	corsPolicy     *corsPolicy
+	authenticators []Authenticator
+	httpClient     *http.Client
+	mountMux       *http.ServeMux
 }
 
 type Option func(*Server)
@@ -34,9 +62,35 @@ func WithPathPrefix(prefix string) Option {
 	}
 }
 
+// WithMux mounts the server on mux under its path prefix.
+//
+// Deprecated: the server is an http.Handler; register it on the mux
+// yourself, e.g. mux.Handle("/admin/", srv).
 func WithMux(mux *http.ServeMux) Option {
 	return func(s *Server) {
-		s.mux = mux
+		s.mountMux = mux
+	}
+}
+
+// WithLogger sets the logger for request and error logs.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithTracer traces every request with tracer. Provider fetches and SQL
+// queries are recorded as child spans.
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(s *Server) {
+		s.tracer = tracer
+	}
+}
+
+// WithBatchWorkers bounds how many widgets of a page are fetched at once.
+func WithBatchWorkers(n int) Option {
+	return func(s *Server) {
+		s.batchWorkers = n
 	}
 }
 
@@ -51,41 +105,141 @@ func New(cfg config.AppConfig, providers providers.Registry, opts ...Option) (*S
 		providers:  providers,
 		indexHTML:  indexHTML,
 		pathPrefix: normalizePrefix(cfg.PathPrefix),
+		metrics:    newMetrics(),
+		logger:     slog.Default(),
+		tracer:     tracing.Noop(),
+		inFlight:   newInFlightLimits(cfg.Providers),
+	}
+	if cfg.RateLimit != nil {
+		srv.rateLimiter = newRateLimiter(*cfg.RateLimit)
 	}
 
 	for _, opt := range opts {
 		opt(srv)
 	}
 
-	if srv.mux == nil {
-		srv.mux = http.DefaultServeMux
+	if srv.batchWorkers <= 0 {
+		srv.batchWorkers = defaultBatchWorkers
+	}
+	renderedHTML, _ := srv.renderIndexHTML()
+	srv.security = newSecurityHeaders(cfg.Security, renderedHTML)
+	srv.corsPolicy = newCORSPolicy(cfg.CORS)
+	srv.handler = compress(srv.trace(srv.cors(srv.secure(srv.authenticate(srv.routes())))))
+	if srv.mountMux != nil {
+		srv.mountMux.Handle(srv.pathPrefix+"/", srv)
 	}
 
 	return srv, nil
 }
 
+// Handler returns the server itself. It is kept for callers that mount the
+// server through a function returning http.Handler.
 func (s *Server) Handler() http.Handler {
-	s.mux.HandleFunc(s.apiConfigPath(), s.handleConfig)
-	s.mux.HandleFunc(s.apiWidgetsPrefix(), s.handleWidgetData)
-	if s.pathPrefix == "" {
-		s.mux.HandleFunc("/", s.handleIndex)
-	} else {
-		s.mux.HandleFunc(s.pathPrefix, s.handleIndex)
-		s.mux.HandleFunc(s.pathPrefix+"/", s.handleIndex)
+	return s
+}
+
+// ServeHTTP serves the API and the UI. Requests may carry the configured
+// path prefix or arrive with it already stripped, e.g. through
+// http.StripPrefix.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.pathPrefix != "" && hasPathPrefix(r.URL.Path, s.pathPrefix) {
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, s.pathPrefix), "/")
+		r2.URL.RawPath = ""
+		r = r2
+	}
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *Server) routes() http.Handler {
+	rt := &router{
+		fallback: s.handleFallback,
+		methodNotAllowed: func(w http.ResponseWriter, r *http.Request) {
+			s.writeError(w, errMethodNotAllowed)
+		},
 	}
-	return s.mux
+	rt.handle(http.MethodGet, "/api/config", s.handleConfig)
+	rt.handle(http.MethodGet, "/api/widgets/{id}", s.instrumentWidget(s.limitWidget(s.handleWidgetData)))
+	rt.handle(http.MethodGet, "/api/widgets/{id}/filters/{filter}/values", s.instrumentWidget(s.handleFilterValues))
+	rt.handle(http.MethodPost, "/api/widgets/{id}/actions/{action}", s.limitWidget(s.handleRowAction))
+	rt.handle(http.MethodGet, "/api/pages/{slug}/data", s.handlePageData)
+	rt.handle(http.MethodGet, "/api/pages/{slug}/filters/{filter}/values", s.handlePageFilterValues)
+	rt.handle(http.MethodDelete, "/api/admin/cache/{id}", s.handlePurgeCache)
+	rt.handle(http.MethodGet, "/api/me", s.handleMe)
+	rt.handle(http.MethodGet, "/auth/{path...}", s.handleAuth)
+	rt.handle(http.MethodPost, "/auth/{path...}", s.handleAuth)
+	rt.handle(http.MethodGet, "/healthz", s.handleHealthz)
+	rt.handle(http.MethodGet, "/readyz", s.handleReadyz)
+	rt.handle(http.MethodGet, "/metrics", s.handleMetrics)
+	return rt
 }
 
-func (s *Server) apiPrefix() string {
-	return s.pathPrefix + "/api/"
+// handleFallback serves the UI for every path outside the API so that
+// client-side routes can be reloaded.
+func (s *Server) handleFallback(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api" || strings.HasPrefix(r.URL.Path, "/api/") {
+		s.writeError(w, errNotFound)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		s.writeError(w, errMethodNotAllowed)
+		return
+	}
+	s.handleIndex(w, r)
 }
 
-func (s *Server) apiConfigPath() string {
-	return s.pathPrefix + "/api/config"
+// ListenAndServe serves the handler on addr until ctx is done, then shuts the
+// HTTP server down gracefully and closes the providers.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	httpServer := &http.Server{Addr: addr, Handler: s.Handler()}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = httpServer.Shutdown(shutdownCtx)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	return errors.Join(err, s.Close())
+}
+
+// Close flushes the audit sinks and closes every provider that holds
+// resources.
+func (s *Server) Close() error {
+	var errs []error
+	for _, sink := range s.auditSinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close audit sink: %w", err))
+			}
+		}
+	}
+	for name, provider := range s.providers {
+		if closer, ok := provider.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close provider %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (s *Server) apiWidgetsPrefix() string {
-	return s.pathPrefix + "/api/widgets/"
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
 }
 
 func normalizePrefix(prefix string) string {
@@ -99,16 +253,16 @@ func normalizePrefix(prefix string) string {
 	return strings.TrimSuffix(prefix, "/")
 }
 
-func (s *Server) renderIndexHTML() []byte {
+func (s *Server) renderIndexHTML() ([]byte, string) {
 	s.renderedOnce.Do(func() {
 		htmlStr := string(s.indexHTML)
 		s.renderedHTML = []byte(strings.Replace(htmlStr, "{{pathPrefix}}", html.EscapeString(s.pathPrefix), 1))
+		s.renderedETag = contentETag(s.renderedHTML)
 	})
-	return s.renderedHTML
+	return s.renderedHTML, s.renderedETag
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(s.renderIndexHTML())
+	body, etag := s.renderIndexHTML()
+	writeCached(w, r, "text/html; charset=utf-8", etag, body)
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ankulikov/rapidmin/config"
+	"github.com/ankulikov/rapidmin/providers"
+)
+
+func statResponse(spec config.StatSpec, data providers.DataResponse) providers.StatResponse {
+	if len(data.Data) == 0 {
+		return providers.StatResponse{}
+	}
+
+	row := data.Data[0]
+	resp := providers.StatResponse{Value: row[spec.ValueColumn()]}
+	if spec.Label != "" {
+		resp.Label = stringValue(row[spec.Label])
+	}
+	if spec.Previous != "" {
+		resp.Previous = row[spec.Previous]
+	}
+
+	value, hasValue := toFloat(resp.Value)
+	if spec.Delta != "" {
+		if delta, ok := toFloat(row[spec.Delta]); ok {
+			resp.Delta = &delta
+		}
+	} else if previous, ok := toFloat(resp.Previous); ok && hasValue {
+		delta := value - previous
+		resp.Delta = &delta
+	}
+
+	if resp.Delta != nil && hasValue {
+		previous := value - *resp.Delta
+		if previous != 0 {
+			percent := *resp.Delta / previous * 100
+			resp.DeltaPercent = &percent
+		}
+	}
+
+	return resp
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
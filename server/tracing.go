@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/ankulikov/rapidmin/tracing"
+)
+
+// trace starts a span per request, continuing the trace of an incoming
+// traceparent header.
+func (s *Server) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := s.tracer.Start(ctx, "HTTP "+r.Method,
+			tracing.String("http.method", r.Method),
+			tracing.String("http.target", r.URL.Path),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(tracing.Int("http.status_code", status))
+	})
+}
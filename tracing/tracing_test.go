@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := map[string]bool{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00":       true,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra": true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra": false,
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":       false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01":       false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01":       false,
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01":       false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":          false,
+		"": false,
+	}
+	for header, valid := range tests {
+		sc, ok := ParseTraceparent(header)
+		if ok != valid {
+			t.Fatalf("ParseTraceparent(%q) ok = %v, expected %v", header, ok, valid)
+		}
+		if ok && header[:2] == "00" && sc.Traceparent() != header {
+			t.Fatalf("Traceparent() = %q, expected %q", sc.Traceparent(), header)
+		}
+	}
+}
+
+func TestTracerLinksSpans(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	tracer := NewTracer(exporter)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+
+	ctx, root := tracer.Start(ctx, "root", String("a", "b"))
+	childCtx, child := Start(ctx, "child")
+	child.SetAttributes(Int("rows", 3))
+
+	out := http.Header{}
+	Inject(childCtx, out)
+	if out.Get(TraceparentHeader) != child.SpanContext().Traceparent() {
+		t.Fatalf("unexpected injected traceparent %q", out.Get(TraceparentHeader))
+	}
+
+	child.End()
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	childData, rootData := spans[0], spans[1]
+	if rootData.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("root did not continue remote trace: %s", rootData.Context.TraceID)
+	}
+	if rootData.Parent.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected root parent %s", rootData.Parent.SpanID)
+	}
+	if childData.Parent != rootData.Context || childData.Attribute("rows") != 3 {
+		t.Fatalf("unexpected child span %+v", childData)
+	}
+}
+
+func TestStartWithoutTracerIsNoop(t *testing.T) {
+	ctx, span := Start(context.Background(), "orphan")
+	span.End()
+	if span.SpanContext().IsValid() || SpanFromContext(ctx).SpanContext().IsValid() {
+		t.Fatalf("expected no-op span")
+	}
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	ctx := ContextWithRemoteSpanContext(context.Background(), SpanContext{
+		TraceID: TraceID{1},
+		SpanID:  SpanID{1},
+	})
+	_, span := NewTracer(exporter).Start(ctx, "unsampled")
+	span.End()
+	if len(exporter.Spans()) != 0 {
+		t.Fatalf("expected unsampled span to be dropped")
+	}
+}